@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
 
 	"smart_attendance_backend/config"
+	"smart_attendance_backend/controllers"
+	"smart_attendance_backend/middleware"
 	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -18,18 +22,50 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Load JWT signing keys
+	if err := services.LoadKeys(config.AppConfig.JWT); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// Select the SMS provider used for OTP delivery
+	if err := services.InitSMS(config.AppConfig.SMS); err != nil {
+		log.Fatalf("Failed to configure SMS provider: %v", err)
+	}
+
+	// Select how OTP codes are generated and checked
+	if err := services.InitOTP(config.AppConfig.OTP, config.AppConfig.SMS); err != nil {
+		log.Fatalf("Failed to configure OTP strategy: %v", err)
+	}
+
 	// Initialize database
 	if err := models.InitDB(config.GetDSN()); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// Start the live event broker
+	if err := services.InitRealtime(context.Background(), config.AppConfig.Realtime); err != nil {
+		log.Fatalf("Failed to start realtime broker: %v", err)
+	}
+
+	// Start background jobs
+	if config.AppConfig.Scheduler.Enabled {
+		scheduler := services.NewScheduler()
+		scheduler.Every("close_expired_sessions", config.AppConfig.Scheduler.SessionCloseInterval, services.CloseExpiredSessions)
+		scheduler.Every("open_timetable_sessions", config.AppConfig.Scheduler.TimetableInterval, func(ctx context.Context) error {
+			return services.OpenScheduledSessions(ctx, config.AppConfig.Scheduler.TimetableLead)
+		})
+		scheduler.Start(context.Background())
+	}
+
 	// Set Gin mode
 	if os.Getenv("GIN_MODE") != "debug" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Initialize router
-	router := gin.Default()
+	// Initialize router. The logger redacts access tokens passed in the query
+	// string to the realtime stream.
+	router := gin.New()
+	router.Use(middleware.Logger(), gin.Recovery())
 
 	// Configure CORS
 	router.Use(cors.New(cors.Config{
@@ -61,41 +97,175 @@ func initializeRoutes(router *gin.Engine) {
 		})
 	})
 
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", controllers.JWKS)
+
 	// API version group
 	v1 := router.Group("/api/v1")
 	{
 		// Auth routes
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/register/teacher", nil) // TODO: Implement handler
-			auth.POST("/register/student", nil) // TODO: Implement handler
-			auth.POST("/verify-otp", nil)       // TODO: Implement handler
-			auth.POST("/login/teacher", nil)    // TODO: Implement handler
-			auth.POST("/login/student", nil)    // TODO: Implement handler
-			auth.POST("/reset-password", nil)   // TODO: Implement handler
+			loginLimit := middleware.RateLimit("login", "email", "roll_number")
+			otpLimit := middleware.RateLimit("otp", "user_id", "identifier")
+
+			auth.POST("/register/teacher", controllers.TeacherRegister)
+			auth.POST("/register/student", controllers.StudentRegister)
+			auth.POST("/verify-otp", otpLimit, controllers.VerifyOTP)
+			auth.POST("/login/teacher", loginLimit, controllers.TeacherLogin)
+			auth.POST("/login/student", loginLimit, controllers.StudentLogin)
+			auth.POST("/reset-password", otpLimit, controllers.ResetPassword)
+			auth.POST("/forgot-password", otpLimit, controllers.ForgotPassword)
+			auth.POST("/otp/resend", otpLimit, controllers.ResendOTP)
+			auth.POST("/refresh", controllers.RefreshToken)
+			auth.POST("/logout", controllers.Logout)
+
+			// Active logins of the signed-in user
+			auth.GET("/sessions", middleware.AuthRequired(), controllers.ListLogins)
+			auth.DELETE("/sessions/:id", middleware.AuthRequired(), controllers.RevokeLogin)
+			auth.POST("/logout-all", middleware.AuthRequired(), controllers.LogoutAll)
+		}
+
+		// Admin routes
+		v1.POST("/admin/login", middleware.RateLimit("admin_login", "username"), controllers.AdminLogin)
+		admin := v1.Group("/admin", middleware.AdminRequired())
+		{
+			admin.POST("/logout", controllers.AdminLogout)
+			admin.POST("/admins/:id/force-logout", controllers.ForceLogoutAdmin)
+			admin.POST("/users/:id/force-logout", controllers.ForceLogoutUser)
+			admin.PATCH("/users/:id/status", controllers.UpdateUserStatus)
+
+			// Campus, building and room registry
+			admin.GET("/campuses", controllers.ListCampuses)
+			admin.POST("/campuses", controllers.CreateCampus)
+			admin.PUT("/campuses/:id", controllers.UpdateCampus)
+			admin.DELETE("/campuses/:id", controllers.DeleteCampus)
+			admin.GET("/buildings", controllers.ListBuildings)
+			admin.POST("/buildings", controllers.CreateBuilding)
+			admin.PUT("/buildings/:id", controllers.UpdateBuilding)
+			admin.DELETE("/buildings/:id", controllers.DeleteBuilding)
+			admin.GET("/rooms", controllers.ListRooms)
+			admin.GET("/rooms/:id", controllers.GetRoom)
+			admin.POST("/rooms", controllers.CreateRoom)
+			admin.PUT("/rooms/:id", controllers.UpdateRoom)
+			admin.DELETE("/rooms/:id", controllers.DeleteRoom)
+			admin.POST("/rooms/:id/access-points", controllers.AddAccessPoint)
+			admin.DELETE("/rooms/:id/access-points/:ap_id", controllers.RemoveAccessPoint)
+
+			// Academic catalogue, teaching assignments and enrolment
+			admin.GET("/academic-years", controllers.ListAcademicYears)
+			admin.POST("/academic-years", controllers.CreateAcademicYear)
+			admin.PUT("/academic-years/:id", controllers.UpdateAcademicYear)
+			admin.DELETE("/academic-years/:id", controllers.DeleteAcademicYear)
+			admin.GET("/courses", controllers.ListCourses)
+			admin.POST("/courses", controllers.CreateCourse)
+			admin.PUT("/courses/:id", controllers.UpdateCourse)
+			admin.DELETE("/courses/:id", controllers.DeleteCourse)
+			admin.GET("/subjects", controllers.ListSubjects)
+			admin.POST("/subjects", controllers.CreateSubject)
+			admin.PUT("/subjects/:id", controllers.UpdateSubject)
+			admin.DELETE("/subjects/:id", controllers.DeleteSubject)
+			admin.GET("/sections", controllers.ListSections)
+			admin.POST("/sections", controllers.CreateSection)
+			admin.PUT("/sections/:id", controllers.UpdateSection)
+			admin.DELETE("/sections/:id", controllers.DeleteSection)
+			admin.GET("/sections/:id/students", controllers.ListSectionStudents)
+			admin.POST("/sections/:id/students", controllers.EnrollStudents)
+			admin.DELETE("/sections/:id/students/:student_id", controllers.UnenrollStudent)
+			admin.GET("/teacher-subjects", controllers.ListTeacherSubjects)
+			admin.POST("/teacher-subjects", controllers.AssignTeacherSubject)
+			admin.DELETE("/teacher-subjects/:id", controllers.UnassignTeacherSubject)
+
+			// Terms and timetable
+			admin.GET("/terms", controllers.ListTerms)
+			admin.POST("/terms", controllers.CreateTerm)
+			admin.PUT("/terms/:id", controllers.UpdateTerm)
+			admin.DELETE("/terms/:id", controllers.DeleteTerm)
+			admin.GET("/timetable", controllers.ListTimetable)
+			admin.POST("/timetable", controllers.CreateTimetableEntry)
+			admin.PUT("/timetable/:id", controllers.UpdateTimetableEntry)
+			admin.DELETE("/timetable/:id", controllers.DeleteTimetableEntry)
+			admin.POST("/timetable/:id/exceptions", controllers.AddTimetableException)
+			admin.DELETE("/timetable/:id/exceptions/:exception_id", controllers.RemoveTimetableException)
+
+			// Academic calendar
+			admin.GET("/calendar/events", controllers.ListCalendarEvents)
+			admin.POST("/calendar/events", controllers.CreateCalendarEvent)
+			admin.PUT("/calendar/events/:id", controllers.UpdateCalendarEvent)
+			admin.DELETE("/calendar/events/:id", controllers.DeleteCalendarEvent)
+			admin.POST("/calendar/import", controllers.ImportCalendar)
+
+			// Device rebind review
+			admin.GET("/device-rebind-requests", controllers.ListRebindRequests)
+			admin.POST("/device-rebind-requests/:id/approve", controllers.ApproveRebindRequest)
+			admin.POST("/device-rebind-requests/:id/reject", controllers.RejectRebindRequest)
 		}
 
 		// Session routes
-		sessions := v1.Group("/sessions")
+		sessions := v1.Group("/sessions", middleware.AuthRequired())
+		{
+			sessions.POST("/start", middleware.RequireRole(models.RoleTeacher), controllers.StartSession)
+			sessions.GET("/active", controllers.ActiveSessions)
+			sessions.PATCH("/end", middleware.RequireRole(models.RoleTeacher), controllers.EndSession)
+			sessions.GET("/:id/qr", middleware.RequireRole(models.RoleTeacher), controllers.SessionQRCode)
+			sessions.POST("/:id/confirm", middleware.RequireRole(models.RoleTeacher), controllers.ConfirmSession)
+		}
+
+		// Terms and non-teaching days
+		v1.GET("/calendar", middleware.AuthRequired(), controllers.AcademicCalendar)
+
+		// The signed-in teacher's timetable
+		timetable := v1.Group("/timetable", middleware.AuthRequired(), middleware.RequireRole(models.RoleTeacher))
 		{
-			sessions.POST("/start", nil) // TODO: Implement handler
-			sessions.GET("/active", nil) // TODO: Implement handler
-			sessions.PATCH("/end", nil)  // TODO: Implement handler
+			timetable.GET("", controllers.MyTimetable)
+			timetable.GET("/upcoming", controllers.UpcomingClasses)
+			timetable.POST("/:id/exceptions", controllers.AddTimetableException)
+			timetable.DELETE("/:id/exceptions/:exception_id", controllers.RemoveTimetableException)
 		}
 
+		// Courses and academic years offered at registration
+		v1.GET("/catalogue", controllers.Catalogue)
+
+		// Subjects the signed-in teacher is assigned to
+		v1.GET("/subjects/mine", middleware.AuthRequired(), middleware.RequireRole(models.RoleTeacher), controllers.MySubjects)
+
+		// Rooms teachers can hold sessions in
+		rooms := v1.Group("/rooms", middleware.AuthRequired(), middleware.RequireRole(models.RoleTeacher))
+		{
+			rooms.GET("", controllers.ListRooms)
+			rooms.GET("/:id", controllers.GetRoom)
+		}
+
+		// Live session events over Server-Sent Events
+		v1.GET("/realtime/stream", middleware.QueryToken(), middleware.AuthRequired(), controllers.RealtimeStream)
+
 		// Attendance routes
-		attendance := v1.Group("/attendance")
+		attendance := v1.Group("/attendance", middleware.AuthRequired())
 		{
-			attendance.POST("/mark", nil)  // TODO: Implement handler
-			attendance.GET("/status", nil) // TODO: Implement handler
+			attendance.POST("/challenge", middleware.RequireRole(models.RoleStudent), controllers.IssueChallenge)
+			attendance.POST("/mark", middleware.RequireRole(models.RoleStudent), controllers.MarkAttendance)
+			attendance.GET("/status", controllers.AttendanceStatus)
+			attendance.PUT("/records", middleware.RequireRole(models.RoleTeacher), controllers.SetAttendanceStatus)
+			attendance.GET("/stats", middleware.RequireRole(models.RoleStudent), controllers.MyAttendanceStats)
+			attendance.GET("/stats/subjects/:id", middleware.RequireRole(models.RoleTeacher), controllers.SubjectAttendanceStats)
 		}
 
 		// Security routes
-		security := v1.Group("/security")
+		security := v1.Group("/security", middleware.AuthRequired())
 		{
 			security.GET("/check-developer-mode", nil) // TODO: Implement handler
-			security.GET("/check-device-binding", nil) // TODO: Implement handler
-			security.POST("/report-fraud", nil)        // TODO: Implement handler
+			security.GET("/check-device-binding", controllers.CheckDeviceBinding)
+			security.POST("/report-fraud", nil) // TODO: Implement handler
+
+			student := middleware.RequireRole(models.RoleStudent)
+			security.POST("/device-rebind", student, controllers.RequestDeviceRebind)
+			security.GET("/device-rebind", student, controllers.ListMyRebindRequests)
+			security.DELETE("/device-rebind/:id", student, controllers.CancelDeviceRebind)
+
+			teacher := middleware.RequireRole(models.RoleTeacher)
+			security.GET("/device-rebind-requests", teacher, controllers.ListRebindRequests)
+			security.POST("/device-rebind-requests/:id/approve", teacher, controllers.ApproveRebindRequest)
+			security.POST("/device-rebind-requests/:id/reject", teacher, controllers.RejectRebindRequest)
 		}
 	}
 }
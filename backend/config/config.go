@@ -5,16 +5,22 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	OTP      OTPConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	JWT        JWTConfig
+	OTP        OTPConfig
+	SMS        SMSConfig
+	Security   SecurityConfig
+	Scheduler  SchedulerConfig
+	Attendance AttendanceConfig
+	Realtime   RealtimeConfig
 }
 
 type ServerConfig struct {
@@ -36,12 +42,119 @@ type JWTConfig struct {
 	TokenExpiry   time.Duration
 	RefreshSecret string
 	RefreshExpiry time.Duration
+	Issuer        string
+	// SigningAlgorithm is HS256 (shared Secret), RS256 or EdDSA (PrivateKeyFile)
+	SigningAlgorithm string
+	PrivateKeyFile   string
+	KeyID            string
+	// VerificationKeyFiles maps a kid to a PEM public key file that is still
+	// accepted for verification, e.g. the previous key during rotation
+	VerificationKeyFiles map[string]string
 }
 
 type OTPConfig struct {
 	Length      int
 	Expiry      time.Duration
 	MaxAttempts int
+	// HashSecret keys the HMAC used to store OTP codes
+	HashSecret string
+	// ResendCooldown is the minimum time between codes for the same purpose
+	ResendCooldown time.Duration
+	// DailyLimit caps the codes a user can request per purpose in 24 hours
+	DailyLimit int
+	// Strategy is local (codes generated and stored here) or twilio_verify
+	Strategy         string
+	VerifyBaseURL    string
+	VerifyServiceSID string
+}
+
+type SMSConfig struct {
+	// Provider is one of twilio, webhook, console or memory
+	Provider         string
+	TwilioBaseURL    string
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFrom       string
+	WebhookURL       string
+	WebhookToken     string
+	Timeout          time.Duration
+}
+
+type SecurityConfig struct {
+	// Request limits applied to auth endpoints per client IP, account
+	// identifier and device within RateLimitWindow
+	RateLimitWindow     time.Duration
+	RateLimitPerIP      int
+	RateLimitPerAccount int
+	RateLimitPerDevice  int
+	// Accounts lock after MaxFailedLogins consecutive failures. The lockout
+	// starts at LockoutDuration and doubles on each repeat, up to MaxLockoutDuration
+	MaxFailedLogins    int
+	LockoutDuration    time.Duration
+	MaxLockoutDuration time.Duration
+	// DeviceRebindCooldown is how long a student must keep a newly bound
+	// device before asking to move to another one
+	DeviceRebindCooldown time.Duration
+}
+
+type SchedulerConfig struct {
+	Enabled bool
+	// SessionCloseInterval is how often expired attendance sessions are closed
+	SessionCloseInterval time.Duration
+	// TimetableInterval is how often sessions are opened for upcoming
+	// auto-open timetable classes
+	TimetableInterval time.Duration
+	// TimetableLead is how long before a class its session is opened for the
+	// teacher to confirm
+	TimetableLead time.Duration
+}
+
+type RealtimeConfig struct {
+	// Broker fans live events out to connected clients: "local" for a single
+	// instance, "database" to share them between instances through the database
+	Broker string
+	// PollInterval is how often the database broker checks for new events
+	PollInterval time.Duration
+	// Retention is how long the database broker keeps delivered events
+	Retention time.Duration
+	// PollOverlap is how far back each database poll looks again, so events
+	// that commit after a newer one has been read are still delivered
+	PollOverlap time.Duration
+	// Heartbeat is how often an idle stream gets a keep-alive comment
+	Heartbeat time.Duration
+}
+
+type AttendanceConfig struct {
+	// GeofenceRadiusMeters is how far from the session location a mark is
+	// accepted when the session does not set its own radius
+	GeofenceRadiusMeters float64
+	// MaxGPSAccuracyMeters rejects fixes less accurate than this
+	MaxGPSAccuracyMeters float64
+	// GeofenceToleranceMeters is how far beyond the geofence boundary a fix
+	// may be, up to its reported accuracy, and still count as inside
+	GeofenceToleranceMeters float64
+	// RequireAllFactors requires both location and Wi-Fi to pass; otherwise
+	// either one is enough as long as neither fails
+	RequireAllFactors bool
+	// ChallengeTTL is how long a device has to sign a mark challenge
+	ChallengeTTL time.Duration
+	// RequireAttestation rejects marks that are not signed by the bound device
+	RequireAttestation bool
+	// QRRotation is how often a session's QR token changes. QRGraceSteps
+	// previous tokens are still accepted to allow for scanning and network delay
+	QRRotation   time.Duration
+	QRGraceSteps int
+	// LateAfter is how long after a session starts a mark counts as late,
+	// unless the subject sets its own threshold. Zero never marks late. It
+	// should be shorter than the countdown, which closes the session; marks
+	// for timetabled classes are timed from the scheduled start instead.
+	LateAfter time.Duration
+	// LateWeight is how much a late mark counts towards the attendance
+	// percentage, from 0 (like an absence) to 1 (like being present)
+	LateWeight float64
+	// ExcusedPolicy is how excused absences count in the percentage:
+	// "exclude" leaves the session out, "present" or "absent" count it as such
+	ExcusedPolicy string
 }
 
 var AppConfig Config
@@ -83,6 +196,12 @@ func Load() error {
 		TokenExpiry:   time.Duration(tokenExpiry) * time.Hour,
 		RefreshSecret: getEnv("JWT_REFRESH_SECRET", "your-refresh-secret-key"),
 		RefreshExpiry: time.Duration(refreshExpiry) * time.Hour,
+		Issuer:        getEnv("JWT_ISSUER", "smart-attendance"),
+
+		SigningAlgorithm:     getEnv("JWT_SIGNING_ALG", "HS256"),
+		PrivateKeyFile:       getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		KeyID:                getEnv("JWT_KEY_ID", ""),
+		VerificationKeyFiles: parseKeyValueList(getEnv("JWT_VERIFICATION_KEYS", "")),
 	}
 
 	// OTP Configuration
@@ -99,10 +218,101 @@ func Load() error {
 		maxAttempts = 3
 	}
 
+	resendCooldown, err := strconv.Atoi(getEnv("OTP_RESEND_COOLDOWN_SECONDS", "60"))
+	if err != nil {
+		resendCooldown = 60
+	}
+	dailyLimit, err := strconv.Atoi(getEnv("OTP_DAILY_LIMIT", "5"))
+	if err != nil {
+		dailyLimit = 5
+	}
+
 	AppConfig.OTP = OTPConfig{
-		Length:      otpLength,
-		Expiry:      time.Duration(otpExpiry) * time.Minute,
-		MaxAttempts: maxAttempts,
+		Length:           otpLength,
+		Expiry:           time.Duration(otpExpiry) * time.Minute,
+		MaxAttempts:      maxAttempts,
+		HashSecret:       getEnv("OTP_HASH_SECRET", AppConfig.JWT.Secret),
+		ResendCooldown:   time.Duration(resendCooldown) * time.Second,
+		DailyLimit:       dailyLimit,
+		Strategy:         getEnv("OTP_STRATEGY", "local"),
+		VerifyBaseURL:    getEnv("TWILIO_VERIFY_BASE_URL", "https://verify.twilio.com"),
+		VerifyServiceSID: getEnv("TWILIO_VERIFY_SERVICE_SID", ""),
+	}
+
+	// SMS Configuration
+	smsTimeout, err := strconv.Atoi(getEnv("SMS_TIMEOUT_SECONDS", "10"))
+	if err != nil {
+		smsTimeout = 10
+	}
+	AppConfig.SMS = SMSConfig{
+		Provider:         getEnv("SMS_PROVIDER", ""),
+		TwilioBaseURL:    getEnv("TWILIO_BASE_URL", "https://api.twilio.com"),
+		TwilioAccountSID: getEnv("TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:  getEnv("TWILIO_AUTH_TOKEN", ""),
+		TwilioFrom:       getEnv("TWILIO_FROM", ""),
+		WebhookURL:       getEnv("SMS_WEBHOOK_URL", ""),
+		WebhookToken:     getEnv("SMS_WEBHOOK_TOKEN", ""),
+		Timeout:          time.Duration(smsTimeout) * time.Second,
+	}
+	if AppConfig.SMS.Provider == "" {
+		// Fall back to logging messages when Twilio is not configured so the
+		// stack can run in development without credentials. The console
+		// provider logs OTP codes, so it is never picked outside debug mode.
+		switch {
+		case AppConfig.SMS.TwilioAccountSID != "" && AppConfig.SMS.TwilioAuthToken != "" && AppConfig.SMS.TwilioFrom != "":
+			AppConfig.SMS.Provider = "twilio"
+		case AppConfig.Server.Environment == "debug":
+			AppConfig.SMS.Provider = "console"
+		default:
+			return fmt.Errorf("no SMS provider configured: set SMS_PROVIDER or the Twilio credentials")
+		}
+	}
+	if AppConfig.SMS.Provider == "console" && AppConfig.Server.Environment != "debug" {
+		log.Println("Warning: SMS_PROVIDER=console logs OTP codes in plain text outside debug mode")
+	}
+
+	// Security Configuration
+	AppConfig.Security = SecurityConfig{
+		RateLimitWindow:      time.Duration(getEnvInt("RATE_LIMIT_WINDOW_MINUTES", 15)) * time.Minute,
+		RateLimitPerIP:       getEnvInt("RATE_LIMIT_PER_IP", 200),
+		RateLimitPerAccount:  getEnvInt("RATE_LIMIT_PER_ACCOUNT", 10),
+		RateLimitPerDevice:   getEnvInt("RATE_LIMIT_PER_DEVICE", 20),
+		MaxFailedLogins:      getEnvInt("MAX_FAILED_LOGINS", 5),
+		LockoutDuration:      time.Duration(getEnvInt("LOCKOUT_MINUTES", 5)) * time.Minute,
+		MaxLockoutDuration:   time.Duration(getEnvInt("MAX_LOCKOUT_MINUTES", 1440)) * time.Minute,
+		DeviceRebindCooldown: time.Duration(getEnvInt("DEVICE_REBIND_COOLDOWN_HOURS", 168)) * time.Hour,
+	}
+
+	// Scheduler Configuration
+	AppConfig.Scheduler = SchedulerConfig{
+		Enabled:              getEnv("SCHEDULER_ENABLED", "true") == "true",
+		SessionCloseInterval: time.Duration(getEnvInt("SESSION_CLOSE_INTERVAL_SECONDS", 10)) * time.Second,
+		TimetableInterval:    time.Duration(getEnvInt("TIMETABLE_OPEN_INTERVAL_SECONDS", 60)) * time.Second,
+		TimetableLead:        time.Duration(getEnvInt("TIMETABLE_OPEN_LEAD_MINUTES", 10)) * time.Minute,
+	}
+
+	// Realtime Configuration
+	AppConfig.Realtime = RealtimeConfig{
+		Broker:       getEnv("REALTIME_BROKER", "local"),
+		PollInterval: time.Duration(getEnvInt("REALTIME_POLL_INTERVAL_MS", 1000)) * time.Millisecond,
+		Retention:    time.Duration(getEnvInt("REALTIME_RETENTION_MINUTES", 10)) * time.Minute,
+		PollOverlap:  time.Duration(getEnvInt("REALTIME_POLL_OVERLAP_SECONDS", 30)) * time.Second,
+		Heartbeat:    time.Duration(getEnvInt("REALTIME_HEARTBEAT_SECONDS", 25)) * time.Second,
+	}
+
+	// Attendance Configuration
+	AppConfig.Attendance = AttendanceConfig{
+		GeofenceRadiusMeters:    getEnvFloat("GEOFENCE_RADIUS_METERS", 50),
+		MaxGPSAccuracyMeters:    getEnvFloat("MAX_GPS_ACCURACY_METERS", 100),
+		GeofenceToleranceMeters: getEnvFloat("GEOFENCE_TOLERANCE_METERS", 10),
+		RequireAllFactors:       getEnv("ATTENDANCE_REQUIRE_ALL_FACTORS", "false") == "true",
+		ChallengeTTL:            time.Duration(getEnvInt("ATTENDANCE_CHALLENGE_TTL_SECONDS", 60)) * time.Second,
+		RequireAttestation:      getEnv("ATTENDANCE_REQUIRE_ATTESTATION", "true") == "true",
+		QRRotation:              time.Duration(getEnvInt("ATTENDANCE_QR_ROTATION_SECONDS", 10)) * time.Second,
+		QRGraceSteps:            getEnvInt("ATTENDANCE_QR_GRACE_STEPS", 1),
+		LateAfter:               time.Duration(getEnvInt("ATTENDANCE_LATE_AFTER_SECONDS", 20)) * time.Second,
+		LateWeight:              getEnvFloat("ATTENDANCE_LATE_WEIGHT", 0.5),
+		ExcusedPolicy:           getEnv("ATTENDANCE_EXCUSED_POLICY", "exclude"),
 	}
 
 	return nil
@@ -124,3 +334,32 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, strconv.Itoa(defaultValue)))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// parseKeyValueList parses "key1=value1,key2=value2" into a map
+func parseKeyValueList(value string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || key == "" || val == "" {
+			continue
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return result
+}
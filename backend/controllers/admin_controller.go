@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+type AdminLoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ForceLogoutRequest struct {
+	Reason string `json:"reason"`
+}
+
+type UpdateUserStatusRequest struct {
+	Status models.UserStatus `json:"status" binding:"required"`
+	Reason string            `json:"reason"`
+}
+
+// AdminLogin authenticates an admin portal user by username or email
+func AdminLogin(c *gin.Context) {
+	var req AdminLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var admin models.Admin
+	if err := models.GetDB().Where("username = ? OR email = ?", req.Username, req.Username).First(&admin).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	token, err := services.GenerateAdminToken(admin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "token": token, "role": admin.Role})
+}
+
+// AdminLogout revokes every token issued to the signed-in admin
+func AdminLogout(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+
+	if err := services.RevokeAdminTokens(admin.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	recordAdminAction(c, admin, "logout", nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// ForceLogoutAdmin revokes every token issued to another admin. Only super
+// admins may do this.
+func ForceLogoutAdmin(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+	if !admin.IsSuperAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource", "code": middleware.ErrCodeForbidden})
+		return
+	}
+
+	var req ForceLogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var target models.Admin
+	if err := models.GetDB().Where("id = ?", c.Param("id")).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admin not found"})
+		return
+	}
+
+	if err := services.RevokeAdminTokens(target.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out admin"})
+		return
+	}
+
+	recordAdminAction(c, admin, "force_logout_admin", gin.H{"admin_id": target.ID, "reason": req.Reason})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Admin logged out of all devices"})
+}
+
+// ForceLogoutUser revokes every login of a user, e.g. when a phone is lost
+func ForceLogoutUser(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+
+	var req ForceLogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	db := models.GetDB()
+	var user models.User
+	if err := db.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := services.RevokeAllLogins(db, user.ID, "admin_force_logout"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out user"})
+		return
+	}
+
+	recordAdminAction(c, admin, "force_logout_user", gin.H{"user_id": user.ID, "reason": req.Reason})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User logged out of all devices"})
+}
+
+// UpdateUserStatus suspends, deactivates or reactivates a user. Every change
+// also logs the user out of all devices. Reactivating an account that never
+// completed verification puts it back to pending verification.
+func UpdateUserStatus(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+
+	var req UpdateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var user models.User
+	if err := db.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	previous := user.Status
+	switch req.Status {
+	case models.UserStatusSuspended:
+		user.Suspend()
+	case models.UserStatusDeactivated:
+		user.Deactivate()
+	case models.UserStatusActive:
+		user.Reactivate()
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be active, suspended or deactivated"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("status", user.Status).Error; err != nil {
+			return err
+		}
+		return services.RevokeAllLogins(tx, user.ID, "account_"+string(user.Status))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
+		return
+	}
+
+	recordAdminAction(c, admin, "update_user_status", gin.H{"user_id": user.ID, "from": previous, "to": user.Status, "reason": req.Reason})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User status updated", "user_id": user.ID, "status": user.Status})
+}
+
+// recordAdminAction writes an admin audit log entry. Failures are not fatal
+// to the request.
+func recordAdminAction(c *gin.Context, admin *models.Admin, action string, details interface{}) {
+	entry := models.AdminAuditLog{AdminID: admin.ID, Action: action, IPAddress: c.ClientIP()}
+	if err := entry.SetDetails(details); err != nil {
+		return
+	}
+	models.GetDB().Create(&entry)
+}
@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// Error codes returned by the attendance endpoints
+const (
+	ErrCodeVerificationFailed   = "verification_failed"
+	ErrCodeAlreadyMarked        = "already_marked"
+	ErrCodeAlreadyMarkedSubject = "already_marked_subject"
+	ErrCodeSessionClosed        = "session_closed"
+	ErrCodeDeviceNotBound       = "device_not_bound"
+	ErrCodeDeviceKeyMissing     = "device_key_missing"
+	ErrCodeSessionNotHeld       = "session_not_held"
+	ErrCodeStudentNotEligible   = "student_not_eligible"
+)
+
+// SetAttendanceRequest is a teacher's decision about one student in a session
+type SetAttendanceRequest struct {
+	SessionID string                  `json:"session_id" binding:"required"`
+	StudentID string                  `json:"student_id" binding:"required"`
+	Status    models.AttendanceStatus `json:"status" binding:"required,oneof=present late absent excused"`
+	Note      *string                 `json:"note" binding:"omitempty,max=255"`
+}
+
+// ChallengeRequest asks for a nonce to sign when marking attendance
+type ChallengeRequest struct {
+	SessionID string `json:"session_id" binding:"required"`
+	DeviceID  string `json:"device_id"`
+}
+
+// MarkAttendanceRequest carries the evidence a student's app collects when marking
+type MarkAttendanceRequest struct {
+	SessionID    string   `json:"session_id" binding:"required"`
+	LocationLat  *float64 `json:"location_lat" binding:"omitempty,min=-90,max=90"`
+	LocationLong *float64 `json:"location_long" binding:"omitempty,min=-180,max=180"`
+	// LocationAccuracy is the GPS accuracy radius reported by the device, in meters
+	LocationAccuracy *float64                `json:"location_accuracy" binding:"omitempty,min=0"`
+	WifiSSID         *string                 `json:"wifi_ssid"`
+	WifiBSSID        *string                 `json:"wifi_bssid"`
+	DeviceInfo       models.DeviceInfoStruct `json:"device_info" binding:"required"`
+	// Nonce comes from /attendance/challenge; Signature is the device key's
+	// signature over services.AttestationPayload
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+	// QRToken is scanned from the teacher's screen in QR mode sessions
+	QRToken string `json:"qr_token"`
+}
+
+// IssueChallenge hands the student's bound device a short-lived nonce to sign
+// for a session
+func IssueChallenge(c *gin.Context) {
+	student := middleware.CurrentUser(c)
+
+	var req ChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DeviceID == "" {
+		req.DeviceID = c.GetHeader(middleware.DeviceIDHeader)
+	}
+
+	var session models.AttendanceSession
+	if err := models.GetDB().Where("id = ?", req.SessionID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if !session.IsOpen() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Session is no longer open", "code": ErrCodeSessionClosed})
+		return
+	}
+
+	device, err := services.ActiveDevice(student.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue challenge"})
+		return
+	}
+	if device == nil || device.DeviceID != req.DeviceID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This device is not registered to your account", "code": ErrCodeDeviceNotBound})
+		return
+	}
+	if device.PublicKey == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "No key is registered for this device. Sign in again to register it.", "code": ErrCodeDeviceKeyMissing})
+		return
+	}
+
+	challenge, err := services.IssueChallenge(student, &session, device)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue challenge"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"nonce":          challenge.Nonce,
+		"expires_at":     challenge.ExpiresAt,
+		"payload_format": "nonce|session_id|location_lat|location_long|wifi_bssid",
+	})
+}
+
+// MarkAttendance verifies a student's presence and records it
+func MarkAttendance(c *gin.Context) {
+	student := middleware.CurrentUser(c)
+
+	var req MarkAttendanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.DeviceInfo.DeviceID == "" {
+		req.DeviceInfo.DeviceID = c.GetHeader(middleware.DeviceIDHeader)
+	}
+
+	db := models.GetDB()
+	var session models.AttendanceSession
+	if err := db.Preload("Room.AccessPoints").Where("id = ?", req.SessionID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	// Checked before verification too, so a repeated tap does not spend the
+	// device's challenge or QR token
+	if existing, err := services.FindExistingMark(db, student.ID, &session, time.Now()); existing != nil {
+		respondAlreadyMarked(c, *existing, err)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark attendance"})
+		return
+	}
+
+	attempt := &services.MarkAttempt{
+		Student:   student,
+		Session:   &session,
+		Latitude:  req.LocationLat,
+		Longitude: req.LocationLong,
+		Accuracy:  req.LocationAccuracy,
+		WifiSSID:  req.WifiSSID,
+		WifiBSSID: req.WifiBSSID,
+		Device:    req.DeviceInfo,
+		Nonce:     req.Nonce,
+		Signature: req.Signature,
+		QRToken:   req.QRToken,
+	}
+	result := services.DefaultVerificationPipeline().Run(c.Request.Context(), attempt)
+	if !result.Passed {
+		audit := models.AuditLog{UserID: student.ID, Action: "attendance_verification_failed", IPAddress: c.ClientIP()}
+		audit.SetDetails(gin.H{"session_id": session.ID, "checks": result.AuditChecks()})
+		db.Create(&audit)
+
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Attendance verification failed",
+			"code":   ErrCodeVerificationFailed,
+			"checks": result.Checks,
+		})
+		return
+	}
+
+	status, err := services.MarkStatus(db, &session, attempt.Now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark attendance"})
+		return
+	}
+
+	record := models.AttendanceRecord{
+		SessionID:          session.ID,
+		StudentID:          student.ID,
+		Status:             status,
+		MarkedAt:           attempt.Now,
+		VerificationMethod: result.Method,
+		LocationLat:        req.LocationLat,
+		LocationLong:       req.LocationLong,
+		WifiSSID:           req.WifiSSID,
+		WifiBSSID:          req.WifiBSSID,
+		LocationAccuracy:   req.LocationAccuracy,
+	}
+	// The distance the geofence check measured, so the record agrees with
+	// what was decided
+	if geofence, ok := result.Check(services.CheckGeofence); ok {
+		if distance, ok := geofence.Internal["distance_meters"].(float64); ok {
+			record.DistanceMeters = &distance
+		}
+	}
+	if err := record.SetDeviceInfo(req.DeviceInfo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device info"})
+		return
+	}
+
+	if existing, err := services.CreateAttendanceRecord(&record, attempt); err != nil {
+		switch {
+		case existing != nil:
+			respondAlreadyMarked(c, *existing, err)
+		case errors.Is(err, services.ErrSessionNotActive):
+			c.JSON(http.StatusConflict, gin.H{"error": "Session is no longer open", "code": ErrCodeSessionClosed})
+		case errors.Is(err, services.ErrQRTokenReplayed), errors.Is(err, services.ErrChallengeUsed):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "QR code or challenge has already been used. Try again", "code": ErrCodeVerificationFailed})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark attendance"})
+		}
+		return
+	}
+
+	services.PublishEvent(services.EventAttendanceMarked, services.AttendanceMarkedEvent{
+		SessionID:          session.ID,
+		TeacherID:          session.TeacherID,
+		RecordID:           record.ID,
+		StudentID:          student.ID,
+		StudentName:        student.FullName,
+		RollNumber:         student.RollNumber,
+		Status:             string(record.Status),
+		VerificationMethod: string(record.VerificationMethod),
+		MarkedAt:           record.MarkedAt,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Attendance marked", "record": studentRecordResponse(record), "checks": result.Checks})
+}
+
+// AttendanceStatus reports a student's record for a session, or the running
+// counts for the teacher who owns it
+func AttendanceStatus(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	sessionID := c.Query("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "session_id is required"})
+		return
+	}
+
+	db := models.GetDB()
+	var session models.AttendanceSession
+	if err := db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if user.IsTeacher() {
+		if session.TeacherID != user.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+
+		var counts []struct {
+			Status models.AttendanceStatus
+			Count  int
+		}
+		if err := db.Model(&models.AttendanceRecord{}).Select("status, COUNT(*) AS count").
+			Where("session_id = ?", session.ID).Group("status").Scan(&counts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendance"})
+			return
+		}
+		summary := gin.H{}
+		for _, row := range counts {
+			summary[string(row.Status)] = row.Count
+		}
+		c.JSON(http.StatusOK, gin.H{"session": sessionResponse(session), "counts": summary})
+		return
+	}
+
+	var record models.AttendanceRecord
+	if err := db.Where("session_id = ? AND student_id = ?", session.ID, user.ID).First(&record).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"session": studentSessionResponse(session), "marked": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session": studentSessionResponse(session), "marked": true, "record": studentRecordResponse(record)})
+}
+
+// SetAttendanceStatus lets the teacher who owns a session mark a student
+// present, late, absent or excused, overriding any verified mark
+func SetAttendanceStatus(c *gin.Context) {
+	teacher := middleware.CurrentUser(c)
+
+	var req SetAttendanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var session models.AttendanceSession
+	if err := db.Where("id = ? AND teacher_id = ?", req.SessionID, teacher.ID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	var student models.User
+	if err := db.Where("id = ? AND role = ?", req.StudentID, models.RoleStudent).First(&student).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+
+	record, created, err := services.SetAttendanceStatus(session.ID, student.ID, req.Status, req.Note, teacher.ID)
+	switch {
+	case errors.Is(err, services.ErrSessionNotHeld):
+		c.JSON(http.StatusConflict, gin.H{"error": "Attendance can only be set for active or completed sessions", "code": ErrCodeSessionNotHeld})
+		return
+	case errors.Is(err, services.ErrStudentNotEligible):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Student is not expected to attend this session", "code": ErrCodeStudentNotEligible})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set attendance"})
+		return
+	}
+
+	audit := models.AuditLog{UserID: teacher.ID, Action: "set_attendance_status", IPAddress: c.ClientIP()}
+	audit.SetDetails(gin.H{"session_id": session.ID, "student_id": student.ID, "status": record.Status, "note": record.Note})
+	db.Create(&audit)
+
+	services.PublishEvent(services.EventAttendanceMarked, services.AttendanceMarkedEvent{
+		SessionID:          session.ID,
+		TeacherID:          session.TeacherID,
+		RecordID:           record.ID,
+		StudentID:          student.ID,
+		StudentName:        student.FullName,
+		RollNumber:         student.RollNumber,
+		Status:             string(record.Status),
+		VerificationMethod: string(record.VerificationMethod),
+		MarkedAt:           record.MarkedAt,
+	})
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"message": "Attendance updated", "record": recordResponse(*record)})
+}
+
+// respondAlreadyMarked answers a repeated mark with the original record. A
+// repeat for the same session succeeds so retries are idempotent; a second
+// session of the same subject on the same day is refused.
+func respondAlreadyMarked(c *gin.Context, record models.AttendanceRecord, err error) {
+	if errors.Is(err, services.ErrAlreadyMarkedSubject) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Attendance already marked for this subject today",
+			"code":   ErrCodeAlreadyMarkedSubject,
+			"record": studentRecordResponse(record),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Attendance already marked",
+		"code":           ErrCodeAlreadyMarked,
+		"already_marked": true,
+		"record":         studentRecordResponse(record),
+	})
+}
+
+// recordResponse renders an attendance record for the teacher who owns the
+// session, including where and how far from the fence it was marked
+func recordResponse(record models.AttendanceRecord) gin.H {
+	resp := studentRecordResponse(record)
+	resp["location_lat"] = record.LocationLat
+	resp["location_long"] = record.LocationLong
+	resp["location_accuracy"] = record.LocationAccuracy
+	resp["distance_meters"] = record.DistanceMeters
+	resp["wifi_bssid"] = record.WifiBSSID
+	return resp
+}
+
+// studentRecordResponse renders an attendance record for the student it
+// belongs to. The coordinates, fence distance and BSSID are left out so a
+// student cannot learn how close to the edge a mark was.
+func studentRecordResponse(record models.AttendanceRecord) gin.H {
+	return gin.H{
+		"id":                  record.ID,
+		"session_id":          record.SessionID,
+		"student_id":          record.StudentID,
+		"status":              record.Status,
+		"marked_at":           record.MarkedAt,
+		"verification_method": record.VerificationMethod,
+		"wifi_ssid":           record.WifiSSID,
+		"marked_by_user_id":   record.MarkedByUserID,
+		"note":                record.Note,
+	}
+}
@@ -3,14 +3,14 @@
 package controllers
 
 import (
-	"math/rand"
+	"errors"
 	"net/http"
-	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
+	"smart_attendance_backend/middleware"
 	"smart_attendance_backend/models"
 	"smart_attendance_backend/services"
 )
@@ -67,6 +67,7 @@ func TeacherRegister(c *gin.Context) {
 		HighestDegree: &req.HighestDegree,
 		Experience:    &req.Experience,
 		PasswordHash:  string(hashedPassword),
+		Status:        models.UserStatusPendingVerification,
 	}
 
 	if err := models.GetDB().Create(&newUser).Error; err != nil {
@@ -74,24 +75,8 @@ func TeacherRegister(c *gin.Context) {
 		return
 	}
 
-	// Generate a 6-digit OTP
-	rand.Seed(time.Now().UnixNano())
-	otp := strconv.Itoa(100000 + rand.Intn(900000))
-
-	// Create OTP verification record
-	otpRec := models.OTPVerification{
-		UserID:    newUser.ID,
-		OTPCode:   otp,
-		ExpiresAt: time.Now().Add(5 * time.Minute),
-	}
-
-	if err := models.GetDB().Create(&otpRec).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create OTP record"})
-		return
-	}
-
-	// Send OTP via Twilio (stub implementation)
-	if err := services.SendSMS(req.Phone, "Your OTP code is: "+otp); err != nil {
+	// Issue and deliver the verification OTP
+	if err := services.SendOTP(c.Request.Context(), newUser, models.OTPPurposeRegistration); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP"})
 		return
 	}
@@ -116,13 +101,28 @@ type TeacherLoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// StudentLoginRequest signs a student in. The device is bound to the account
+// on first login; device_id may also be sent in the X-Device-ID header.
 type StudentLoginRequest struct {
-	RollNumber string `json:"roll_number" binding:"required"`
-	Password   string `json:"password" binding:"required"`
+	RollNumber  string  `json:"roll_number" binding:"required"`
+	Password    string  `json:"password" binding:"required"`
+	DeviceID    string  `json:"device_id" binding:"max=255"`
+	PublicKey   *string `json:"public_key"`
+	DeviceModel *string `json:"device_model" binding:"omitempty,max=100"`
+	DeviceBrand *string `json:"device_brand" binding:"omitempty,max=100"`
+	OSVersion   *string `json:"os_version" binding:"omitempty,max=50"`
 }
 
+type VerifyOTPRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+	OTP    string `json:"otp" binding:"required"`
+}
+
+// ResetPasswordRequest identifies the user either by ID or by the roll number,
+// email or phone used with forgot-password
 type ResetPasswordRequest struct {
-	UserID             string `json:"user_id" binding:"required"`
+	UserID             string `json:"user_id" binding:"required_without=Identifier"`
+	Identifier         string `json:"identifier"`
 	OTP                string `json:"otp" binding:"required"`
 	NewPassword        string `json:"new_password" binding:"required,min=6"`
 	ConfirmNewPassword string `json:"confirm_new_password" binding:"required,min=6"`
@@ -147,6 +147,19 @@ func StudentRegister(c *gin.Context) {
 		return
 	}
 
+	// Course and academic year must come from the catalogue so sessions
+	// match the right students
+	course, err := services.ResolveCourse(req.Course)
+	if err != nil {
+		respondCatalogueError(c, err)
+		return
+	}
+	year, err := services.ResolveAcademicYear(req.AcademicYear)
+	if err != nil {
+		respondCatalogueError(c, err)
+		return
+	}
+
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -160,35 +173,20 @@ func StudentRegister(c *gin.Context) {
 		FullName:     req.FullName,
 		Phone:        req.Phone,
 		PasswordHash: string(hashedPassword),
+		Status:       models.UserStatusPendingVerification,
 	}
 	// Set student-specific fields
 	newUser.RollNumber = &req.RollNumber
-	newUser.Course = &req.Course
-	newUser.AcademicYear = &req.AcademicYear
+	newUser.Course = &course.Code
+	newUser.AcademicYear = &year.Name
 
 	if err := models.GetDB().Create(&newUser).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	// Generate a 6-digit OTP
-	rand.Seed(time.Now().UnixNano())
-	otp := strconv.Itoa(100000 + rand.Intn(900000))
-
-	// Create OTP verification record
-	otpRec := models.OTPVerification{
-		UserID:    newUser.ID,
-		OTPCode:   otp,
-		ExpiresAt: time.Now().Add(5 * time.Minute),
-	}
-
-	if err := models.GetDB().Create(&otpRec).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create OTP record"})
-		return
-	}
-
-	// Send OTP via Twilio (stub implementation)
-	if err := services.SendSMS(req.Phone, "Your OTP code is: "+otp); err != nil {
+	// Issue and deliver the verification OTP
+	if err := services.SendOTP(c.Request.Context(), newUser, models.OTPPurposeRegistration); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP"})
 		return
 	}
@@ -205,24 +203,17 @@ func TeacherLogin(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := models.GetDB().Where("email = ? AND role = ?", req.Email, models.RoleTeacher).First(&user).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+	err := models.GetDB().Where("email = ? AND role = ?", req.Email, models.RoleTeacher).First(&user).Error
+	if !checkCredentials(c, "teacher:"+req.Email, &user, err == nil, req.Password) {
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	if resp, blocked := middleware.AccountStatusError(user); blocked {
+		c.JSON(http.StatusForbidden, resp)
 		return
 	}
 
-	// Generate JWT token
-	token, err := services.GenerateToken(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "token": token})
+	respondWithTokens(c, user, nil)
 }
 
 // StudentLogin handles student login
@@ -234,23 +225,46 @@ func StudentLogin(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := models.GetDB().Where("roll_number = ? AND role = ?", req.RollNumber, models.RoleStudent).First(&user).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+	err := models.GetDB().Where("roll_number = ? AND role = ?", req.RollNumber, models.RoleStudent).First(&user).Error
+	if !checkCredentials(c, "student:"+req.RollNumber, &user, err == nil, req.Password) {
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	if resp, blocked := middleware.AccountStatusError(user); blocked {
+		c.JSON(http.StatusForbidden, resp)
 		return
 	}
 
-	token, err := services.GenerateToken(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	deviceID := req.DeviceID
+	if deviceID == "" {
+		deviceID = c.GetHeader(middleware.DeviceIDHeader)
+	}
+	if deviceID == "" {
+		respondWithTokens(c, user, gin.H{"device": gin.H{"bound": false}})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "token": token})
+	// A different device can still sign in, e.g. to request a rebind, but
+	// cannot mark attendance
+	device, err := services.BindDevice(&user, services.DeviceDetails{
+		DeviceID:    deviceID,
+		PublicKey:   req.PublicKey,
+		DeviceModel: req.DeviceModel,
+		DeviceBrand: req.DeviceBrand,
+		OSVersion:   req.OSVersion,
+	}, c.ClientIP())
+	switch {
+	case err == nil:
+		respondWithTokens(c, user, gin.H{"device": gin.H{"bound": true, "device_id": device.DeviceID}})
+	case errors.Is(err, services.ErrDeviceMismatch):
+		respondWithTokens(c, user, gin.H{"device": gin.H{"bound": false, "code": ErrCodeDeviceMismatch}})
+	case errors.Is(err, services.ErrDeviceBoundElsewhere):
+		respondWithTokens(c, user, gin.H{"device": gin.H{"bound": false, "code": ErrCodeDeviceInUse}})
+	case errors.Is(err, services.ErrInvalidPublicKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to bind device"})
+	}
 }
 
 // ResetPassword handles password reset via OTP
@@ -265,48 +279,168 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
-	db := models.GetDB()
-	var otpRecord models.OTPVerification
-	if err := db.Where("user_id = ? AND verified = ?", req.UserID, false).Order("created_at desc").First(&otpRecord).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "OTP record not found"})
+	user, err := findUser(req.UserID, req.Identifier)
+	if err != nil {
+		// Same response as a missing code so unknown accounts are not revealed
+		respondOTPError(c, services.ErrOTPNotFound)
 		return
 	}
 
-	if otpRecord.IsExpired() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "OTP has expired. Please request a new one."})
+	if user.IsLocked() {
+		middleware.AbortTooManyAttempts(c, services.LockoutRemaining(user))
+		return
+	}
+
+	if user.IsSuspended() || user.IsDeactivated() {
+		resp, _ := middleware.AccountStatusError(*user)
+		c.JSON(http.StatusForbidden, resp)
 		return
 	}
 
-	if otpRecord.OTPCode != req.OTP {
-		otpRecord.IncrementAttempts()
-		db.Save(&otpRecord)
-		if otpRecord.HasExceededMaxAttempts(3) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "OTP verification failed, maximum attempts exceeded."})
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OTP."})
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash new password"})
+		return
+	}
+
+	// The password is saved in the transaction that spends the code, and every
+	// existing login is revoked with it so a stolen token stops working
+	err = services.CheckOTP(c.Request.Context(), *user, models.OTPPurposePasswordReset, req.OTP, func(tx *gorm.DB) error {
+		user.PasswordHash = string(hashedPassword)
+		user.ResetFailedLogins()
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return services.RevokeAllLogins(tx, user.ID, "password_reset")
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrOTPInvalid) || errors.Is(err, services.ErrOTPMaxAttempts) {
+			if locked, _ := services.RecordFailedLogin(user, "invalid_reset_otp", c.ClientIP()); locked {
+				middleware.AbortTooManyAttempts(c, services.LockoutRemaining(user))
+				return
+			}
 		}
+		respondOTPError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// VerifyOTP confirms the registration OTP and marks the user as verified
+func VerifyOTP(c *gin.Context) {
+	var req VerifyOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Update user's password
+	db := models.GetDB()
 	var user models.User
 	if err := db.Where("id = ?", req.UserID).First(&user).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if user.IsSuspended() || user.IsDeactivated() {
+		resp, _ := middleware.AccountStatusError(user)
+		c.JSON(http.StatusForbidden, resp)
+		return
+	}
+
+	if user.Verified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User is already verified"})
+		return
+	}
+
+	// The user is activated in the transaction that spends the code
+	err := services.CheckOTP(c.Request.Context(), user, models.OTPPurposeRegistration, req.OTP, func(tx *gorm.DB) error {
+		user.MarkAsVerified()
+		return tx.Save(&user).Error
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash new password"})
+		respondOTPError(c, err)
 		return
 	}
-	user.PasswordHash = string(hashedPassword)
-	if err := db.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Phone number verified successfully", "user_id": user.ID, "status": user.Status})
+}
+
+// dummyPasswordHash is compared against when no account matches a sign-in, so
+// unknown accounts take as long to reject as wrong passwords
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("smart-attendance-dummy-password"), bcrypt.DefaultCost)
+
+// checkCredentials verifies a sign-in attempt and applies the lockout policy.
+// Unknown accounts and wrong passwords get the same response, including the
+// lockout, which is tracked per identifier when no account matches. It
+// writes the error response itself and returns false when the attempt is
+// rejected.
+func checkCredentials(c *gin.Context, identifier string, user *models.User, found bool, password string) bool {
+	if !found {
+		if remaining := services.UnknownLoginLockout(identifier); remaining > 0 {
+			middleware.AbortTooManyAttempts(c, remaining)
+			return false
+		}
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		if remaining := services.RecordUnknownLogin(identifier); remaining > 0 {
+			middleware.AbortTooManyAttempts(c, remaining)
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return false
+	}
+
+	if user.IsLocked() {
+		middleware.AbortTooManyAttempts(c, services.LockoutRemaining(user))
+		return false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		if locked, _ := services.RecordFailedLogin(user, "invalid_password", c.ClientIP()); locked {
+			middleware.AbortTooManyAttempts(c, services.LockoutRemaining(user))
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return false
+	}
+
+	services.ClearFailedLogins(user)
+	return true
+}
+
+// respondWithTokens starts a new login for the user and returns its token
+// pair along with any extra response fields
+func respondWithTokens(c *gin.Context, user models.User, extra gin.H) {
+	pair, err := services.IssueTokenPair(user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	otpRecord.MarkAsVerified()
-	db.Save(&otpRecord)
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+	resp := gin.H{
+		"message":       "Login successful",
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+	}
+	for key, value := range extra {
+		resp[key] = value
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// respondOTPError maps OTP check failures to client responses
+func respondOTPError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOTPNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OTP record not found"})
+	case errors.Is(err, services.ErrOTPExpired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OTP has expired. Please request a new one."})
+	case errors.Is(err, services.ErrOTPMaxAttempts):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OTP verification failed, maximum attempts exceeded."})
+	case errors.Is(err, services.ErrOTPInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OTP."})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify OTP"})
+	}
 }
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// maxCalendarImportBytes bounds the size of an uploaded iCalendar file
+const maxCalendarImportBytes = 2 << 20
+
+type CalendarEventRequest struct {
+	Kind        models.CalendarEventKind `json:"kind" binding:"required"`
+	Title       string                   `json:"title" binding:"required,max=255"`
+	Description *string                  `json:"description"`
+	StartsOn    string                   `json:"starts_on" binding:"required,datetime=2006-01-02"`
+	EndsOn      string                   `json:"ends_on" binding:"required,datetime=2006-01-02"`
+}
+
+// AcademicCalendar returns the terms and non-teaching days overlapping the
+// from and to dates (default: the next 90 days)
+func AcademicCalendar(c *gin.Context) {
+	from, to, ok := calendarRange(c, 90)
+	if !ok {
+		return
+	}
+
+	db := models.GetDB()
+	var terms []models.Term
+	if err := db.Where("starts_on <= ? AND ends_on >= ?", to.Format("2006-01-02"), from.Format("2006-01-02")).
+		Order("starts_on").Find(&terms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+		return
+	}
+	var events []models.CalendarEvent
+	if err := db.Where("starts_on <= ? AND ends_on >= ?", to.Format("2006-01-02"), from.Format("2006-01-02")).
+		Order("starts_on").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"terms": terms, "events": events})
+}
+
+// ListCalendarEvents returns calendar events, optionally filtered by kind and
+// by the from and to dates
+func ListCalendarEvents(c *gin.Context) {
+	query := models.GetDB().Order("starts_on")
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("ends_on >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("starts_on <= ?", to)
+	}
+
+	var events []models.CalendarEvent
+	if err := query.Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar events"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// CreateCalendarEvent adds a holiday, exam period or closure. Sessions still
+// waiting to be confirmed on those days are cancelled.
+func CreateCalendarEvent(c *gin.Context) {
+	var req CalendarEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var event models.CalendarEvent
+	if !applyCalendarEventRequest(c, &event, req) {
+		return
+	}
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return services.CancelScheduledSessionsBetween(tx, event.StartsOn, event.EndsOn)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar event"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_calendar_event", gin.H{"event_id": event.ID, "kind": event.Kind})
+	c.JSON(http.StatusCreated, gin.H{"message": "Calendar event created", "event": event})
+}
+
+// UpdateCalendarEvent replaces a calendar event
+func UpdateCalendarEvent(c *gin.Context) {
+	var req CalendarEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var event models.CalendarEvent
+	if err := db.Where("id = ?", c.Param("id")).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar event not found"})
+		return
+	}
+	if !applyCalendarEventRequest(c, &event, req) {
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&event).Error; err != nil {
+			return err
+		}
+		return services.CancelScheduledSessionsBetween(tx, event.StartsOn, event.EndsOn)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update calendar event"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_calendar_event", gin.H{"event_id": event.ID, "kind": event.Kind})
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar event updated", "event": event})
+}
+
+// DeleteCalendarEvent removes a calendar event, making its days teaching
+// days again
+func DeleteCalendarEvent(c *gin.Context) {
+	result := models.GetDB().Where("id = ?", c.Param("id")).Delete(&models.CalendarEvent{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete calendar event"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar event not found"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_calendar_event", gin.H{"event_id": c.Param("id")})
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar event deleted"})
+}
+
+// ImportCalendar loads terms and non-teaching days from an iCalendar (.ics)
+// file, sent as the "file" form field or as the request body. Events whose
+// CATEGORIES do not say what they are get the default_kind query parameter
+// (default holiday).
+func ImportCalendar(c *gin.Context) {
+	defaultKind := models.CalendarEventKind(c.DefaultQuery("default_kind", string(models.CalendarEventHoliday)))
+	if !models.IsValidCalendarEventKind(defaultKind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "default_kind must be holiday, exam_period or closure"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCalendarImportBytes)
+	var body io.Reader = c.Request.Body
+	if file, _, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		body = file
+	}
+
+	events, err := services.ParseICalendar(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Calendar file is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid iCalendar file: " + err.Error()})
+		return
+	}
+
+	result, err := services.ImportCalendar(events, defaultKind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import calendar"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "import_calendar", result)
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar imported", "result": result})
+}
+
+func applyCalendarEventRequest(c *gin.Context, event *models.CalendarEvent, req CalendarEventRequest) bool {
+	if !models.IsValidCalendarEventKind(req.Kind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be holiday, exam_period or closure"})
+		return false
+	}
+	startsOn, _ := time.ParseInLocation("2006-01-02", req.StartsOn, time.Local)
+	endsOn, _ := time.ParseInLocation("2006-01-02", req.EndsOn, time.Local)
+	if endsOn.Before(startsOn) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_on must not be before starts_on"})
+		return false
+	}
+
+	event.Kind = req.Kind
+	event.Title = req.Title
+	event.Description = req.Description
+	event.StartsOn = startsOn
+	event.EndsOn = endsOn
+	return true
+}
+
+// calendarRange reads the from and to date query parameters, defaulting to
+// today and defaultDays after from
+func calendarRange(c *gin.Context, defaultDays int) (time.Time, time.Time, bool) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+	to := from.AddDate(0, 0, defaultDays)
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
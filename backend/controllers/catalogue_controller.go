@@ -0,0 +1,778 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// Error codes returned by the catalogue and teaching assignment checks
+const (
+	ErrCodeUnknownCourse       = "unknown_course"
+	ErrCodeUnknownAcademicYear = "unknown_academic_year"
+	ErrCodeUnknownSubject      = "unknown_subject"
+	ErrCodeUnknownSection      = "unknown_section"
+	ErrCodeSubjectNotAssigned  = "subject_not_assigned"
+	ErrCodeDuplicateName       = "duplicate_name"
+	ErrCodeAlreadyAssigned     = "already_assigned"
+)
+
+type AcademicYearRequest struct {
+	Name     string  `json:"name" binding:"required,max=50"`
+	StartsOn *string `json:"starts_on" binding:"omitempty,datetime=2006-01-02"`
+	EndsOn   *string `json:"ends_on" binding:"omitempty,datetime=2006-01-02"`
+	Active   *bool   `json:"active"`
+}
+
+type CourseRequest struct {
+	Code string `json:"code" binding:"required,max=50"`
+	Name string `json:"name" binding:"required"`
+}
+
+type SubjectRequest struct {
+	Code     string  `json:"code" binding:"required,max=50"`
+	Name     string  `json:"name" binding:"required"`
+	CourseID *string `json:"course_id"`
+	// LateAfterSeconds overrides the default late threshold; 0 turns late
+	// marks off for the subject
+	LateAfterSeconds *int `json:"late_after_seconds" binding:"omitempty,min=0"`
+}
+
+type SectionRequest struct {
+	CourseID       string `json:"course_id" binding:"required"`
+	AcademicYearID string `json:"academic_year_id" binding:"required"`
+	Name           string `json:"name" binding:"required,max=50"`
+}
+
+type TeacherSubjectRequest struct {
+	TeacherID string  `json:"teacher_id" binding:"required"`
+	SubjectID string  `json:"subject_id" binding:"required"`
+	SectionID *string `json:"section_id"`
+}
+
+type EnrollStudentsRequest struct {
+	StudentIDs []string `json:"student_ids" binding:"required,min=1,max=500"`
+}
+
+// Catalogue returns the courses and open academic years students can
+// register into
+func Catalogue(c *gin.Context) {
+	db := models.GetDB()
+	var courses []models.Course
+	var years []models.AcademicYear
+	if err := db.Order("name").Find(&courses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load catalogue"})
+		return
+	}
+	if err := db.Where("active = ?", true).Order("name").Find(&years).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load catalogue"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"courses": courses, "academic_years": years})
+}
+
+// ListAcademicYears returns every academic year, open or not
+func ListAcademicYears(c *gin.Context) {
+	var years []models.AcademicYear
+	if err := models.GetDB().Order("name").Find(&years).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load academic years"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"academic_years": years})
+}
+
+// CreateAcademicYear registers an academic year
+func CreateAcademicYear(c *gin.Context) {
+	var req AcademicYearRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	if nameTaken(db.Model(&models.AcademicYear{}), req.Name, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Academic year already exists", "code": ErrCodeDuplicateName})
+		return
+	}
+
+	year := models.AcademicYear{Active: true}
+	if !applyAcademicYearRequest(c, &year, req) {
+		return
+	}
+	if err := db.Create(&year).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create academic year"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_academic_year", gin.H{"academic_year_id": year.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Academic year created", "academic_year": year})
+}
+
+// UpdateAcademicYear replaces an academic year's details. Renaming it also
+// renames it on the students, sessions and timetable entries that hold the
+// old name.
+func UpdateAcademicYear(c *gin.Context) {
+	var req AcademicYearRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var year models.AcademicYear
+	if err := db.Where("id = ?", c.Param("id")).First(&year).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Academic year not found"})
+		return
+	}
+	if nameTaken(db.Model(&models.AcademicYear{}), req.Name, year.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Academic year already exists", "code": ErrCodeDuplicateName})
+		return
+	}
+
+	oldName := year.Name
+	if !applyAcademicYearRequest(c, &year, req) {
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&year).Error; err != nil {
+			return err
+		}
+		if oldName == year.Name {
+			return nil
+		}
+		if err := tx.Model(&models.User{}).Where("academic_year = ?", oldName).Update("academic_year", year.Name).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.AttendanceSession{}).Where("academic_year = ?", oldName).Update("academic_year", year.Name).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.TimetableEntry{}).Where("academic_year = ?", oldName).Update("academic_year", year.Name).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update academic year"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_academic_year", gin.H{"academic_year_id": year.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Academic year updated", "academic_year": year})
+}
+
+// DeleteAcademicYear removes an academic year no student or section uses
+func DeleteAcademicYear(c *gin.Context) {
+	db := models.GetDB()
+	var year models.AcademicYear
+	if err := db.Where("id = ?", c.Param("id")).First(&year).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Academic year not found"})
+		return
+	}
+
+	var sections, students int64
+	db.Model(&models.Section{}).Where("academic_year_id = ?", year.ID).Count(&sections)
+	db.Model(&models.User{}).Where("academic_year = ?", year.Name).Count(&students)
+	if sections > 0 || students > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Academic year has sections or students. Close it instead", "code": ErrCodeInUse})
+		return
+	}
+
+	if err := db.Delete(&year).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete academic year"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_academic_year", gin.H{"academic_year_id": year.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Academic year deleted"})
+}
+
+// ListCourses returns every course
+func ListCourses(c *gin.Context) {
+	var courses []models.Course
+	if err := models.GetDB().Order("name").Find(&courses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load courses"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"courses": courses})
+}
+
+// CreateCourse registers a course
+func CreateCourse(c *gin.Context) {
+	var req CourseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	if codeTaken(db.Model(&models.Course{}), req.Code, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Course code already exists", "code": ErrCodeDuplicateCode})
+		return
+	}
+
+	course := models.Course{Code: req.Code, Name: req.Name}
+	if err := db.Create(&course).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create course"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_course", gin.H{"course_id": course.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Course created", "course": course})
+}
+
+// UpdateCourse replaces a course's details. Changing its code also changes it
+// on the students that hold the old code.
+func UpdateCourse(c *gin.Context) {
+	var req CourseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var course models.Course
+	if err := db.Where("id = ?", c.Param("id")).First(&course).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		return
+	}
+	if codeTaken(db.Model(&models.Course{}), req.Code, course.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Course code already exists", "code": ErrCodeDuplicateCode})
+		return
+	}
+
+	oldCode := course.Code
+	course.Code = req.Code
+	course.Name = req.Name
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&course).Error; err != nil {
+			return err
+		}
+		if oldCode == course.Code {
+			return nil
+		}
+		return tx.Model(&models.User{}).Where("course = ?", oldCode).Update("course", course.Code).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update course"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_course", gin.H{"course_id": course.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Course updated", "course": course})
+}
+
+// DeleteCourse removes a course no subject, section or student uses
+func DeleteCourse(c *gin.Context) {
+	db := models.GetDB()
+	var course models.Course
+	if err := db.Where("id = ?", c.Param("id")).First(&course).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		return
+	}
+
+	var subjects, sections, students int64
+	db.Model(&models.Subject{}).Where("course_id = ?", course.ID).Count(&subjects)
+	db.Model(&models.Section{}).Where("course_id = ?", course.ID).Count(&sections)
+	db.Model(&models.User{}).Where("course = ?", course.Code).Count(&students)
+	if subjects > 0 || sections > 0 || students > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Course has subjects, sections or students", "code": ErrCodeInUse})
+		return
+	}
+
+	if err := db.Delete(&course).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete course"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_course", gin.H{"course_id": course.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Course deleted"})
+}
+
+// ListSubjects returns subjects, optionally filtered by course_id
+func ListSubjects(c *gin.Context) {
+	query := models.GetDB().Preload("Course").Order("code")
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+
+	var subjects []models.Subject
+	if err := query.Find(&subjects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load subjects"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subjects": subjects})
+}
+
+// CreateSubject registers a subject
+func CreateSubject(c *gin.Context) {
+	var req SubjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	if !courseExists(c, req.CourseID) {
+		return
+	}
+	if codeTaken(db.Model(&models.Subject{}), req.Code, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Subject code already exists", "code": ErrCodeDuplicateCode})
+		return
+	}
+
+	subject := models.Subject{Code: req.Code, Name: req.Name, CourseID: req.CourseID, LateAfterSeconds: req.LateAfterSeconds}
+	if err := db.Omit("Course").Create(&subject).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subject"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_subject", gin.H{"subject_id": subject.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Subject created", "subject": subject})
+}
+
+// UpdateSubject replaces a subject's details
+func UpdateSubject(c *gin.Context) {
+	var req SubjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var subject models.Subject
+	if err := db.Where("id = ?", c.Param("id")).First(&subject).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subject not found"})
+		return
+	}
+	if !courseExists(c, req.CourseID) {
+		return
+	}
+	if codeTaken(db.Model(&models.Subject{}), req.Code, subject.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Subject code already exists", "code": ErrCodeDuplicateCode})
+		return
+	}
+
+	subject.Code = req.Code
+	subject.Name = req.Name
+	subject.CourseID = req.CourseID
+	subject.LateAfterSeconds = req.LateAfterSeconds
+	if err := db.Omit("Course").Save(&subject).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subject"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_subject", gin.H{"subject_id": subject.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Subject updated", "subject": subject})
+}
+
+// DeleteSubject removes a subject that is not assigned or used by a session
+func DeleteSubject(c *gin.Context) {
+	db := models.GetDB()
+	var subject models.Subject
+	if err := db.Where("id = ?", c.Param("id")).First(&subject).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subject not found"})
+		return
+	}
+
+	var assignments, sessions int64
+	db.Model(&models.TeacherSubject{}).Where("subject_id = ?", subject.ID).Count(&assignments)
+	db.Model(&models.AttendanceSession{}).Where("subject_id = ?", subject.ID).Count(&sessions)
+	if assignments > 0 || sessions > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Subject is assigned or has sessions", "code": ErrCodeInUse})
+		return
+	}
+
+	if err := db.Delete(&subject).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subject"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_subject", gin.H{"subject_id": subject.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Subject deleted"})
+}
+
+// ListSections returns sections, optionally filtered by course_id and
+// academic_year_id
+func ListSections(c *gin.Context) {
+	query := models.GetDB().Preload("Course").Preload("AcademicYear").Order("name")
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if yearID := c.Query("academic_year_id"); yearID != "" {
+		query = query.Where("academic_year_id = ?", yearID)
+	}
+
+	var sections []models.Section
+	if err := query.Find(&sections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sections"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sections": sections})
+}
+
+// CreateSection registers a section of a course in an academic year
+func CreateSection(c *gin.Context) {
+	var req SectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	section := models.Section{}
+	if !applySectionRequest(c, &section, req) {
+		return
+	}
+	if err := models.GetDB().Omit("Course", "AcademicYear").Create(&section).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create section"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_section", gin.H{"section_id": section.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Section created", "section": section})
+}
+
+// UpdateSection replaces a section's details
+func UpdateSection(c *gin.Context) {
+	var req SectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var section models.Section
+	if err := db.Where("id = ?", c.Param("id")).First(&section).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Section not found"})
+		return
+	}
+	if !applySectionRequest(c, &section, req) {
+		return
+	}
+	if err := db.Omit("Course", "AcademicYear").Save(&section).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update section"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_section", gin.H{"section_id": section.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Section updated", "section": section})
+}
+
+// DeleteSection removes a section with no students, assignments or sessions
+func DeleteSection(c *gin.Context) {
+	db := models.GetDB()
+	var section models.Section
+	if err := db.Where("id = ?", c.Param("id")).First(&section).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Section not found"})
+		return
+	}
+
+	var enrollments, assignments, sessions int64
+	db.Model(&models.SectionEnrollment{}).Where("section_id = ?", section.ID).Count(&enrollments)
+	db.Model(&models.TeacherSubject{}).Where("section_id = ?", section.ID).Count(&assignments)
+	db.Model(&models.AttendanceSession{}).Where("section_id = ?", section.ID).Count(&sessions)
+	if enrollments > 0 || assignments > 0 || sessions > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Section has students, assignments or sessions", "code": ErrCodeInUse})
+		return
+	}
+
+	if err := db.Delete(&section).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete section"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_section", gin.H{"section_id": section.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Section deleted"})
+}
+
+// ListTeacherSubjects returns teaching assignments, optionally filtered by
+// teacher_id or subject_id
+func ListTeacherSubjects(c *gin.Context) {
+	query := models.GetDB().Preload("Teacher").Preload("Subject").Preload("Section").Order("created_at")
+	if teacherID := c.Query("teacher_id"); teacherID != "" {
+		query = query.Where("teacher_id = ?", teacherID)
+	}
+	if subjectID := c.Query("subject_id"); subjectID != "" {
+		query = query.Where("subject_id = ?", subjectID)
+	}
+
+	var assignments []models.TeacherSubject
+	if err := query.Find(&assignments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load assignments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"assignments": assignments})
+}
+
+// AssignTeacherSubject lets a teacher run sessions for a subject, for every
+// section or for the given one
+func AssignTeacherSubject(c *gin.Context) {
+	var req TeacherSubjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var teacher models.User
+	if err := db.Where("id = ? AND role = ?", req.TeacherID, models.RoleTeacher).First(&teacher).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Teacher not found"})
+		return
+	}
+	var subject models.Subject
+	if err := db.Where("id = ?", req.SubjectID).First(&subject).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject not found", "code": ErrCodeUnknownSubject})
+		return
+	}
+
+	existing := db.Model(&models.TeacherSubject{}).Where("teacher_id = ? AND subject_id = ?", teacher.ID, subject.ID)
+	if req.SectionID != nil {
+		var section models.Section
+		if err := db.Where("id = ?", *req.SectionID).First(&section).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Section not found", "code": ErrCodeUnknownSection})
+			return
+		}
+		existing = existing.Where("section_id = ?", section.ID)
+	} else {
+		existing = existing.Where("section_id IS NULL")
+	}
+	var count int64
+	existing.Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Subject is already assigned to this teacher", "code": ErrCodeAlreadyAssigned})
+		return
+	}
+
+	assignment := models.TeacherSubject{TeacherID: teacher.ID, SubjectID: subject.ID, SectionID: req.SectionID}
+	if err := db.Omit(clause.Associations).Create(&assignment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign subject"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "assign_subject", gin.H{"assignment_id": assignment.ID, "teacher_id": teacher.ID, "subject_id": subject.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Subject assigned", "assignment": assignment})
+}
+
+// UnassignTeacherSubject removes a teaching assignment. Sessions already run
+// under it are kept.
+func UnassignTeacherSubject(c *gin.Context) {
+	db := models.GetDB()
+	var assignment models.TeacherSubject
+	if err := db.Where("id = ?", c.Param("id")).First(&assignment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+		return
+	}
+	if err := db.Delete(&assignment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove assignment"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "unassign_subject", gin.H{"assignment_id": assignment.ID, "teacher_id": assignment.TeacherID, "subject_id": assignment.SubjectID})
+	c.JSON(http.StatusOK, gin.H{"message": "Assignment removed"})
+}
+
+// MySubjects returns the signed-in teacher's teaching assignments
+func MySubjects(c *gin.Context) {
+	teacher := middleware.CurrentUser(c)
+	var assignments []models.TeacherSubject
+	if err := models.GetDB().Preload("Subject.Course").Preload("Section.AcademicYear").
+		Where("teacher_id = ?", teacher.ID).Order("created_at").Find(&assignments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load subjects"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"assignments": assignments})
+}
+
+// ListSectionStudents returns the students enrolled in a section
+func ListSectionStudents(c *gin.Context) {
+	var enrollments []models.SectionEnrollment
+	if err := models.GetDB().Preload("Student").Where("section_id = ?", c.Param("id")).
+		Order("created_at").Find(&enrollments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load students"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enrollments": enrollments})
+}
+
+// EnrollStudents adds students to a section. Students already enrolled are
+// left as they are; IDs that are not students are returned in not_found.
+func EnrollStudents(c *gin.Context) {
+	var req EnrollStudentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var section models.Section
+	if err := db.Where("id = ?", c.Param("id")).First(&section).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Section not found"})
+		return
+	}
+
+	var studentIDs []string
+	if err := db.Model(&models.User{}).Where("id IN ? AND role = ?", req.StudentIDs, models.RoleStudent).
+		Pluck("id", &studentIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enrol students"})
+		return
+	}
+	found := make(map[string]bool, len(studentIDs))
+	enrollments := make([]models.SectionEnrollment, 0, len(studentIDs))
+	for _, id := range studentIDs {
+		found[id] = true
+		enrollments = append(enrollments, models.SectionEnrollment{SectionID: section.ID, StudentID: id})
+	}
+	notFound := []string{}
+	for _, id := range req.StudentIDs {
+		if !found[id] {
+			notFound = append(notFound, id)
+		}
+	}
+
+	var enrolled int64
+	if len(enrollments) > 0 {
+		result := db.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&enrollments)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enrol students"})
+			return
+		}
+		enrolled = result.RowsAffected
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "enroll_students", gin.H{"section_id": section.ID, "enrolled": enrolled})
+	c.JSON(http.StatusOK, gin.H{"message": "Students enrolled", "enrolled": enrolled, "not_found": notFound})
+}
+
+// UnenrollStudent removes a student from a section
+func UnenrollStudent(c *gin.Context) {
+	result := models.GetDB().Where("section_id = ? AND student_id = ?", c.Param("id"), c.Param("student_id")).
+		Delete(&models.SectionEnrollment{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove student"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student is not enrolled in this section"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "unenroll_student", gin.H{"section_id": c.Param("id"), "student_id": c.Param("student_id")})
+	c.JSON(http.StatusOK, gin.H{"message": "Student removed from section"})
+}
+
+// respondCatalogueError maps catalogue lookup and teaching assignment errors
+// to responses
+func respondCatalogueError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownCourse):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown course", "code": ErrCodeUnknownCourse})
+	case errors.Is(err, services.ErrUnknownAcademicYear):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or closed academic year", "code": ErrCodeUnknownAcademicYear})
+	case errors.Is(err, services.ErrUnknownSubject):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject not found", "code": ErrCodeUnknownSubject})
+	case errors.Is(err, services.ErrUnknownSection):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Section not found", "code": ErrCodeUnknownSection})
+	case errors.Is(err, services.ErrAcademicYearNeeded):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "academic_year is required without a section_id"})
+	case errors.Is(err, services.ErrAcademicYearClash):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "academic_year does not match the section"})
+	case errors.Is(err, services.ErrSubjectNotAssigned):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned to teach this subject", "code": ErrCodeSubjectNotAssigned})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check catalogue"})
+	}
+}
+
+func applyAcademicYearRequest(c *gin.Context, year *models.AcademicYear, req AcademicYearRequest) bool {
+	startsOn, err := parseDate(req.StartsOn)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid starts_on"})
+		return false
+	}
+	endsOn, err := parseDate(req.EndsOn)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ends_on"})
+		return false
+	}
+	if startsOn != nil && endsOn != nil && endsOn.Before(*startsOn) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_on must not be before starts_on"})
+		return false
+	}
+
+	year.Name = req.Name
+	year.StartsOn = startsOn
+	year.EndsOn = endsOn
+	if req.Active != nil {
+		year.Active = *req.Active
+	}
+	return true
+}
+
+func applySectionRequest(c *gin.Context, section *models.Section, req SectionRequest) bool {
+	db := models.GetDB()
+	var count int64
+	db.Model(&models.Course{}).Where("id = ?", req.CourseID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Course not found", "code": ErrCodeUnknownCourse})
+		return false
+	}
+	db.Model(&models.AcademicYear{}).Where("id = ?", req.AcademicYearID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Academic year not found", "code": ErrCodeUnknownAcademicYear})
+		return false
+	}
+
+	duplicate := db.Model(&models.Section{}).
+		Where("course_id = ? AND academic_year_id = ? AND name = ?", req.CourseID, req.AcademicYearID, req.Name)
+	if section.ID != "" {
+		duplicate = duplicate.Where("id <> ?", section.ID)
+	}
+	duplicate.Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Section already exists for this course and year", "code": ErrCodeDuplicateName})
+		return false
+	}
+
+	section.CourseID = req.CourseID
+	section.AcademicYearID = req.AcademicYearID
+	section.Name = req.Name
+	return true
+}
+
+func courseExists(c *gin.Context, courseID *string) bool {
+	if courseID == nil {
+		return true
+	}
+	var count int64
+	models.GetDB().Model(&models.Course{}).Where("id = ?", *courseID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Course not found", "code": ErrCodeUnknownCourse})
+		return false
+	}
+	return true
+}
+
+func nameTaken(scope *gorm.DB, name, exceptID string) bool {
+	query := scope.Where("name = ?", name)
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
+	}
+	var count int64
+	query.Count(&count)
+	return count > 0
+}
+
+func parseDate(value *string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", *value)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// Error codes returned by the device binding endpoints
+const (
+	ErrCodeDeviceMismatch   = "device_mismatch"
+	ErrCodeDeviceInUse      = "device_in_use"
+	ErrCodeRebindPending    = "rebind_pending"
+	ErrCodeRebindSameDevice = "rebind_same_device"
+	ErrCodeRebindCooldown   = "rebind_cooldown"
+	ErrCodeRebindNotPending = "rebind_not_pending"
+)
+
+type RebindDeviceRequest struct {
+	DeviceID    string  `json:"device_id" binding:"required,max=255"`
+	PublicKey   *string `json:"public_key"`
+	DeviceModel *string `json:"device_model" binding:"omitempty,max=100"`
+	DeviceBrand *string `json:"device_brand" binding:"omitempty,max=100"`
+	OSVersion   *string `json:"os_version" binding:"omitempty,max=50"`
+	Reason      string  `json:"reason" binding:"required,max=1000"`
+}
+
+type ReviewRebindRequest struct {
+	Note *string `json:"note" binding:"omitempty,max=1000"`
+}
+
+// CheckDeviceBinding reports the device bound to the signed-in user and
+// whether the calling device (device_id query or X-Device-ID header) is it
+func CheckDeviceBinding(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	device, err := services.ActiveDevice(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check device binding"})
+		return
+	}
+
+	deviceID := c.Query("device_id")
+	if deviceID == "" {
+		deviceID = c.GetHeader(middleware.DeviceIDHeader)
+	}
+
+	resp := gin.H{"bound": device != nil, "matches": device != nil && deviceID != "" && device.DeviceID == deviceID}
+	if device != nil {
+		resp["device"] = device
+	}
+
+	var pending models.DeviceRebindRequest
+	if err := models.GetDB().Where("user_id = ? AND status = ?", user.ID, models.RebindStatusPending).First(&pending).Error; err == nil {
+		resp["pending_rebind"] = pending
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RequestDeviceRebind asks for the student's binding to move to a new device
+func RequestDeviceRebind(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	var req RebindDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := services.RequestRebind(user, services.DeviceDetails{
+		DeviceID:    req.DeviceID,
+		PublicKey:   req.PublicKey,
+		DeviceModel: req.DeviceModel,
+		DeviceBrand: req.DeviceBrand,
+		OSVersion:   req.OSVersion,
+	}, req.Reason, c.ClientIP())
+	if err != nil {
+		respondRebindError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Device change requested. A teacher or admin will review it.", "request": request})
+}
+
+// ListMyRebindRequests returns the signed-in student's rebind requests
+func ListMyRebindRequests(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	var requests []models.DeviceRebindRequest
+	if err := models.GetDB().Where("user_id = ?", user.ID).Order("created_at desc").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rebind requests"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// CancelDeviceRebind withdraws one of the student's pending rebind requests
+func CancelDeviceRebind(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	if err := services.CancelRebind(user.ID, c.Param("id")); err != nil {
+		respondRebindError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Rebind request cancelled"})
+}
+
+// ListRebindRequests returns rebind requests for review, pending ones by
+// default, and can be narrowed down with academic_year. Teachers only see
+// requests from students they teach.
+func ListRebindRequests(c *gin.Context) {
+	status := c.DefaultQuery("status", string(models.RebindStatusPending))
+
+	db := models.GetDB()
+	query := db.Preload("User").
+		Where("device_rebind_requests.status = ?", status).
+		Order("device_rebind_requests.created_at asc")
+	if middleware.CurrentAdmin(c) == nil {
+		query = query.Where("device_rebind_requests.user_id IN (?)", services.TaughtStudentIDs(db, middleware.CurrentUser(c).ID))
+	}
+	if year := c.Query("academic_year"); year != "" {
+		query = query.Joins("JOIN users ON users.id = device_rebind_requests.user_id").
+			Where("users.academic_year = ?", year)
+	}
+	if limit, err := strconv.Atoi(c.DefaultQuery("limit", "100")); err == nil && limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var requests []models.DeviceRebindRequest
+	if err := query.Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rebind requests"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// ApproveRebindRequest binds the student to the requested device
+func ApproveRebindRequest(c *gin.Context) {
+	reviewRebind(c, true)
+}
+
+// RejectRebindRequest keeps the student's current binding
+func RejectRebindRequest(c *gin.Context) {
+	reviewRebind(c, false)
+}
+
+func reviewRebind(c *gin.Context, approve bool) {
+	var req ReviewRebindRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var reviewer services.RebindReviewer
+	admin := middleware.CurrentAdmin(c)
+	if admin != nil {
+		reviewer.AdminID = &admin.ID
+	} else {
+		reviewer.UserID = &middleware.CurrentUser(c).ID
+	}
+
+	request, err := services.ReviewRebind(c.Param("id"), reviewer, approve, req.Note, c.ClientIP())
+	if err != nil {
+		respondRebindError(c, err)
+		return
+	}
+
+	if admin != nil {
+		action := "reject_device_rebind"
+		if approve {
+			action = "approve_device_rebind"
+		}
+		recordAdminAction(c, admin, action, gin.H{"request_id": request.ID, "user_id": request.UserID})
+	}
+
+	message := "Rebind request rejected"
+	if approve {
+		message = "Rebind request approved"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "request": request})
+}
+
+// respondRebindError maps device binding failures to client responses
+func respondRebindError(c *gin.Context, err error) {
+	var cooldown *services.RebindCooldownError
+	switch {
+	case errors.As(err, &cooldown):
+		c.Header("Retry-After", strconv.Itoa(int(cooldown.RetryAfter.Round(time.Second).Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Your device was changed recently. Try again later.",
+			"code":        ErrCodeRebindCooldown,
+			"retry_after": int(cooldown.RetryAfter.Seconds()),
+		})
+	case errors.Is(err, services.ErrInvalidPublicKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRebindPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending device change request", "code": ErrCodeRebindPending})
+	case errors.Is(err, services.ErrRebindSameDevice):
+		c.JSON(http.StatusConflict, gin.H{"error": "This device is already bound to your account", "code": ErrCodeRebindSameDevice})
+	case errors.Is(err, services.ErrDeviceBoundElsewhere):
+		c.JSON(http.StatusConflict, gin.H{"error": "This device is registered to another account", "code": ErrCodeDeviceInUse})
+	case errors.Is(err, services.ErrRebindNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Rebind request has already been reviewed", "code": ErrCodeRebindNotPending})
+	case errors.Is(err, services.ErrRebindNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rebind request not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process rebind request"})
+	}
+}
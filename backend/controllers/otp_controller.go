@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// otpRequestedMessage is returned whether or not an account matched, and
+// whether or not a code was actually sent, so the endpoints cannot be used to
+// discover registered users. Clients are throttled per IP and identifier by
+// the rate limiting middleware instead.
+const otpRequestedMessage = "If an account matches, a new OTP has been sent to its phone number."
+
+// ResendOTPRequest identifies the user by ID or by roll number, email or phone
+type ResendOTPRequest struct {
+	UserID     string            `json:"user_id" binding:"required_without=Identifier"`
+	Identifier string            `json:"identifier"`
+	Purpose    models.OTPPurpose `json:"purpose"`
+}
+
+type ForgotPasswordRequest struct {
+	Identifier string `json:"identifier" binding:"required"`
+}
+
+// ResendOTP issues a fresh code for a pending OTP request. Every outcome gets
+// otpRequestedMessage, so it reveals nothing about the account.
+func ResendOTP(c *gin.Context) {
+	var req ResendOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Purpose == "" {
+		req.Purpose = models.OTPPurposeRegistration
+	}
+	if !req.Purpose.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OTP purpose"})
+		return
+	}
+
+	user, err := findUser(req.UserID, req.Identifier)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": otpRequestedMessage})
+		return
+	}
+
+	// Refusals get the same answer as an unknown account; the reason is
+	// only logged
+	if user.IsSuspended() || user.IsDeactivated() {
+		log.Printf("otp resend refused for user %s: account is %s", user.ID, user.Status)
+		c.JSON(http.StatusOK, gin.H{"message": otpRequestedMessage})
+		return
+	}
+
+	if req.Purpose == models.OTPPurposeRegistration && user.Verified {
+		log.Printf("otp resend refused for user %s: already verified", user.ID)
+		c.JSON(http.StatusOK, gin.H{"message": otpRequestedMessage})
+		return
+	}
+
+	// A resend only replaces a code that was requested through its own flow
+	var previous int64
+	if err := models.GetDB().Model(&models.OTPVerification{}).
+		Where("user_id = ? AND purpose = ? AND verified = ?", user.ID, req.Purpose, false).
+		Count(&previous).Error; err != nil {
+		log.Printf("otp resend for user %s: failed to look up pending requests: %v", user.ID, err)
+		c.JSON(http.StatusOK, gin.H{"message": otpRequestedMessage})
+		return
+	}
+	if previous == 0 {
+		log.Printf("otp resend refused for user %s: no pending %s request", user.ID, req.Purpose)
+		c.JSON(http.StatusOK, gin.H{"message": otpRequestedMessage})
+		return
+	}
+
+	if err := services.SendOTP(c.Request.Context(), *user, req.Purpose); err != nil {
+		logOTPSendError(user, req.Purpose, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": otpRequestedMessage})
+}
+
+// ForgotPassword sends a password reset OTP to the account matching the roll
+// number, email or phone
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := findUser("", req.Identifier)
+	if err != nil || user.IsSuspended() || user.IsDeactivated() {
+		c.JSON(http.StatusOK, gin.H{"message": otpRequestedMessage})
+		return
+	}
+
+	if err := services.SendOTP(c.Request.Context(), *user, models.OTPPurposePasswordReset); err != nil {
+		logOTPSendError(user, models.OTPPurposePasswordReset, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": otpRequestedMessage})
+}
+
+// findUser looks a user up by ID, or by roll number, email or phone
+func findUser(userID, identifier string) (*models.User, error) {
+	var user models.User
+	db := models.GetDB()
+	var err error
+	if userID != "" {
+		err = db.Where("id = ?", userID).First(&user).Error
+	} else {
+		err = db.Where("roll_number = ? OR email = ? OR phone = ?", identifier, identifier, identifier).
+			Order("created_at asc").First(&user).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// logOTPSendError records why no code was sent. The client still gets
+// otpRequestedMessage: a throttled or failed send only happens for real
+// accounts, so answering differently would reveal them.
+func logOTPSendError(user *models.User, purpose models.OTPPurpose, err error) {
+	var throttled *services.OTPThrottledError
+	if errors.As(err, &throttled) {
+		log.Printf("otp %s for user %s throttled (daily limit: %v, retry after %s)", purpose, user.ID, throttled.DailyLimitReached, throttled.RetryAfter)
+		return
+	}
+	log.Printf("otp %s for user %s: failed to send: %v", purpose, user.ID, err)
+}
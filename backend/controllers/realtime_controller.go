@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/services"
+)
+
+// RealtimeStream streams live session events over Server-Sent Events.
+// Teachers receive marks and running counts for their own sessions; students
+// receive sessions starting and closing for their sections and academic year. The stream
+// ends when the access token expires so the client reconnects with a fresh one.
+func RealtimeStream(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	claims := middleware.CurrentClaims(c)
+
+	topics := []string{services.UserTopic(user.ID)}
+	if user.IsStudent() {
+		if user.AcademicYear != nil {
+			topics = append(topics, services.AcademicYearTopic(*user.AcademicYear))
+		}
+		sectionIDs, err := services.StudentSectionIDs(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open stream"})
+			return
+		}
+		for _, sectionID := range sectionIDs {
+			topics = append(topics, services.SectionTopic(sectionID))
+		}
+	}
+	client := services.SubscribeRealtime(topics...)
+	defer services.UnsubscribeRealtime(client)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(config.AppConfig.Realtime.Heartbeat)
+	defer heartbeat.Stop()
+
+	var expired <-chan time.Time
+	if claims != nil && claims.ExpiresAt != nil {
+		timer := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	fmt.Fprintf(c.Writer, "retry: 3000\nevent: ready\ndata: {\"user_id\":%q}\n\n", user.ID)
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-expired:
+			fmt.Fprint(w, "event: token_expired\ndata: {}\n\n")
+			return false
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		case msg := <-client.Messages:
+			if msg.ID != 0 {
+				fmt.Fprintf(w, "id: %d\n", msg.ID)
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, msg.Payload)
+			return true
+		}
+	})
+}
@@ -0,0 +1,445 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// Error codes returned by the room registry endpoints
+const (
+	ErrCodeDuplicateCode  = "duplicate_code"
+	ErrCodeDuplicateBSSID = "duplicate_bssid"
+	ErrCodeInUse          = "in_use"
+)
+
+type CampusRequest struct {
+	Name    string  `json:"name" binding:"required"`
+	Code    string  `json:"code" binding:"required,max=50"`
+	Address *string `json:"address"`
+}
+
+type BuildingRequest struct {
+	CampusID string `json:"campus_id" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Code     string `json:"code" binding:"required,max=50"`
+}
+
+type RoomRequest struct {
+	BuildingID      string            `json:"building_id" binding:"required"`
+	Name            string            `json:"name" binding:"required"`
+	Code            string            `json:"code" binding:"required,max=50"`
+	Floor           *int              `json:"floor"`
+	Capacity        *int              `json:"capacity" binding:"omitempty,min=1"`
+	LocationLat     *float64          `json:"location_lat" binding:"required,min=-90,max=90"`
+	LocationLong    *float64          `json:"location_long" binding:"required,min=-180,max=180"`
+	GeofenceRadius  *float64          `json:"geofence_radius_meters" binding:"omitempty,gt=0,max=5000"`
+	GeofencePolygon []models.GeoPoint `json:"geofence_polygon" binding:"omitempty,min=3,dive"`
+}
+
+type AccessPointRequest struct {
+	SSID  string  `json:"ssid" binding:"required"`
+	BSSID string  `json:"bssid" binding:"required"`
+	Label *string `json:"label"`
+}
+
+// ListCampuses returns every campus
+func ListCampuses(c *gin.Context) {
+	var campuses []models.Campus
+	if err := models.GetDB().Order("name").Find(&campuses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load campuses"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"campuses": campuses})
+}
+
+// CreateCampus registers a campus
+func CreateCampus(c *gin.Context) {
+	var req CampusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	if codeTaken(db.Model(&models.Campus{}), req.Code, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Campus code already exists", "code": ErrCodeDuplicateCode})
+		return
+	}
+
+	campus := models.Campus{Name: req.Name, Code: req.Code, Address: req.Address}
+	if err := db.Create(&campus).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create campus"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_campus", gin.H{"campus_id": campus.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Campus created", "campus": campus})
+}
+
+// UpdateCampus replaces a campus's details
+func UpdateCampus(c *gin.Context) {
+	var req CampusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var campus models.Campus
+	if err := db.Where("id = ?", c.Param("id")).First(&campus).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campus not found"})
+		return
+	}
+	if codeTaken(db.Model(&models.Campus{}), req.Code, campus.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Campus code already exists", "code": ErrCodeDuplicateCode})
+		return
+	}
+
+	campus.Name = req.Name
+	campus.Code = req.Code
+	campus.Address = req.Address
+	if err := db.Save(&campus).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update campus"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_campus", gin.H{"campus_id": campus.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Campus updated", "campus": campus})
+}
+
+// DeleteCampus removes a campus that has no buildings
+func DeleteCampus(c *gin.Context) {
+	db := models.GetDB()
+	var campus models.Campus
+	if err := db.Where("id = ?", c.Param("id")).First(&campus).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campus not found"})
+		return
+	}
+
+	var buildings int64
+	db.Model(&models.Building{}).Where("campus_id = ?", campus.ID).Count(&buildings)
+	if buildings > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Remove the campus's buildings first", "code": ErrCodeInUse})
+		return
+	}
+
+	if err := db.Delete(&campus).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete campus"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_campus", gin.H{"campus_id": campus.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Campus deleted"})
+}
+
+// ListBuildings returns buildings, optionally filtered by campus_id
+func ListBuildings(c *gin.Context) {
+	query := models.GetDB().Preload("Campus").Order("name")
+	if campusID := c.Query("campus_id"); campusID != "" {
+		query = query.Where("campus_id = ?", campusID)
+	}
+
+	var buildings []models.Building
+	if err := query.Find(&buildings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load buildings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"buildings": buildings})
+}
+
+// CreateBuilding registers a building on a campus
+func CreateBuilding(c *gin.Context) {
+	var req BuildingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var campus models.Campus
+	if err := db.Where("id = ?", req.CampusID).First(&campus).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Campus not found"})
+		return
+	}
+	if codeTaken(db.Model(&models.Building{}).Where("campus_id = ?", campus.ID), req.Code, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Building code already exists on this campus", "code": ErrCodeDuplicateCode})
+		return
+	}
+
+	building := models.Building{CampusID: campus.ID, Name: req.Name, Code: req.Code}
+	if err := db.Omit("Campus").Create(&building).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create building"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_building", gin.H{"building_id": building.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Building created", "building": building})
+}
+
+// UpdateBuilding replaces a building's details
+func UpdateBuilding(c *gin.Context) {
+	var req BuildingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var building models.Building
+	if err := db.Where("id = ?", c.Param("id")).First(&building).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+		return
+	}
+	var campus models.Campus
+	if err := db.Where("id = ?", req.CampusID).First(&campus).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Campus not found"})
+		return
+	}
+	if codeTaken(db.Model(&models.Building{}).Where("campus_id = ?", campus.ID), req.Code, building.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Building code already exists on this campus", "code": ErrCodeDuplicateCode})
+		return
+	}
+
+	building.CampusID = campus.ID
+	building.Name = req.Name
+	building.Code = req.Code
+	if err := db.Omit("Campus").Save(&building).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update building"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_building", gin.H{"building_id": building.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Building updated", "building": building})
+}
+
+// DeleteBuilding removes a building that has no rooms
+func DeleteBuilding(c *gin.Context) {
+	db := models.GetDB()
+	var building models.Building
+	if err := db.Where("id = ?", c.Param("id")).First(&building).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+		return
+	}
+
+	var rooms int64
+	db.Model(&models.Room{}).Where("building_id = ?", building.ID).Count(&rooms)
+	if rooms > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Remove the building's rooms first", "code": ErrCodeInUse})
+		return
+	}
+
+	if err := db.Delete(&building).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete building"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_building", gin.H{"building_id": building.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Building deleted"})
+}
+
+// ListRooms returns rooms with their access points, optionally filtered by
+// building_id. Teachers use it to pick the room a session is held in.
+func ListRooms(c *gin.Context) {
+	query := models.GetDB().Preload("Building").Preload("AccessPoints").Order("name")
+	if buildingID := c.Query("building_id"); buildingID != "" {
+		query = query.Where("building_id = ?", buildingID)
+	}
+
+	var rooms []models.Room
+	if err := query.Find(&rooms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rooms"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rooms": rooms})
+}
+
+// GetRoom returns a room with its building and access points
+func GetRoom(c *gin.Context) {
+	var room models.Room
+	err := models.GetDB().Preload("Building.Campus").Preload("AccessPoints").
+		Where("id = ?", c.Param("id")).First(&room).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"room": room})
+}
+
+// CreateRoom registers a room in a building
+func CreateRoom(c *gin.Context) {
+	var req RoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var room models.Room
+	if !applyRoomRequest(c, &room, req) {
+		return
+	}
+	if err := models.GetDB().Omit("Building", "AccessPoints").Create(&room).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_room", gin.H{"room_id": room.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Room created", "room": room})
+}
+
+// UpdateRoom replaces a room's details. Running sessions keep the location
+// they started with.
+func UpdateRoom(c *gin.Context) {
+	var req RoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var room models.Room
+	if err := models.GetDB().Where("id = ?", c.Param("id")).First(&room).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+	if !applyRoomRequest(c, &room, req) {
+		return
+	}
+	if err := models.GetDB().Omit("Building", "AccessPoints").Save(&room).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_room", gin.H{"room_id": room.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Room updated", "room": room})
+}
+
+// DeleteRoom removes a room and its access points
+func DeleteRoom(c *gin.Context) {
+	db := models.GetDB()
+	var room models.Room
+	if err := db.Where("id = ?", c.Param("id")).First(&room).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("room_id = ?", room.ID).Delete(&models.RoomAccessPoint{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&room).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_room", gin.H{"room_id": room.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Room deleted"})
+}
+
+// AddAccessPoint registers a Wi-Fi access point in a room
+func AddAccessPoint(c *gin.Context) {
+	var req AccessPointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !services.ValidBSSID(req.BSSID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid BSSID"})
+		return
+	}
+
+	db := models.GetDB()
+	var room models.Room
+	if err := db.Where("id = ?", c.Param("id")).First(&room).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
+	bssid := services.NormalizeBSSID(req.BSSID)
+	var existing models.RoomAccessPoint
+	if err := db.Where("bssid = ?", bssid).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Access point is already registered", "code": ErrCodeDuplicateBSSID, "room_id": existing.RoomID})
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add access point"})
+		return
+	}
+
+	ap := models.RoomAccessPoint{RoomID: room.ID, SSID: req.SSID, BSSID: bssid, Label: req.Label}
+	if err := db.Create(&ap).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add access point"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "add_access_point", gin.H{"room_id": room.ID, "bssid": bssid})
+	c.JSON(http.StatusCreated, gin.H{"message": "Access point added", "access_point": ap})
+}
+
+// RemoveAccessPoint unregisters one of a room's access points
+func RemoveAccessPoint(c *gin.Context) {
+	db := models.GetDB()
+	var ap models.RoomAccessPoint
+	if err := db.Where("id = ? AND room_id = ?", c.Param("ap_id"), c.Param("id")).First(&ap).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Access point not found"})
+		return
+	}
+
+	if err := db.Delete(&ap).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove access point"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "remove_access_point", gin.H{"room_id": ap.RoomID, "bssid": ap.BSSID})
+	c.JSON(http.StatusOK, gin.H{"message": "Access point removed"})
+}
+
+// applyRoomRequest validates req and copies it onto room. It writes the error
+// response and returns false when the request is invalid.
+func applyRoomRequest(c *gin.Context, room *models.Room, req RoomRequest) bool {
+	db := models.GetDB()
+	var building models.Building
+	if err := db.Where("id = ?", req.BuildingID).First(&building).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Building not found"})
+		return false
+	}
+	if codeTaken(db.Model(&models.Room{}).Where("building_id = ?", building.ID), req.Code, room.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Room code already exists in this building", "code": ErrCodeDuplicateCode})
+		return false
+	}
+	if !validGeofencePolygon(req.GeofencePolygon) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid geofence polygon coordinates"})
+		return false
+	}
+
+	room.BuildingID = building.ID
+	room.Name = req.Name
+	room.Code = req.Code
+	room.Floor = req.Floor
+	room.Capacity = req.Capacity
+	room.LocationLat = *req.LocationLat
+	room.LocationLong = *req.LocationLong
+	room.GeofenceRadius = req.GeofenceRadius
+	if err := room.SetGeofencePolygon(req.GeofencePolygon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid geofence polygon"})
+		return false
+	}
+	return true
+}
+
+// codeTaken reports whether another row in scope already uses code.
+func codeTaken(scope *gorm.DB, code, exceptID string) bool {
+	query := scope.Where("code = ?", code)
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
+	}
+	var count int64
+	query.Count(&count)
+	return count > 0
+}
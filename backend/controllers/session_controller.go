@@ -0,0 +1,399 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// Error codes returned by the session endpoints
+const (
+	ErrCodeSessionOverlap      = "session_overlap"
+	ErrCodeSessionNotActive    = "session_not_active"
+	ErrCodeQRNotEnabled        = "qr_not_enabled"
+	ErrCodeSessionNotScheduled = "session_not_scheduled"
+)
+
+var (
+	errSessionOverlap      = errors.New("teacher already has an active session")
+	errSessionNotScheduled = errors.New("session is not waiting to be confirmed")
+)
+
+// StartSessionRequest describes the session and the classroom it runs in.
+// The subject must be assigned to the teacher. A session is for one section,
+// or for a whole academic year when section_id is omitted.
+// With a room_id the location, geofence and access points come from the room
+// registry; otherwise the Wi-Fi network and coordinates are required.
+type StartSessionRequest struct {
+	SubjectID         string   `json:"subject_id" binding:"required"`
+	AcademicYear      string   `json:"academic_year"`
+	SectionID         *string  `json:"section_id"`
+	CountdownDuration string   `json:"countdown_duration" binding:"required,oneof=30s 1m 3m"`
+	RoomID            string   `json:"room_id"`
+	WifiSSID          string   `json:"wifi_ssid"`
+	WifiBSSID         string   `json:"wifi_bssid"`
+	LocationLat       *float64 `json:"location_lat" binding:"omitempty,min=-90,max=90"`
+	LocationLong      *float64 `json:"location_long" binding:"omitempty,min=-180,max=180"`
+	// GeofenceRadius overrides the default geofence radius, in meters
+	GeofenceRadius *float64 `json:"geofence_radius_meters" binding:"omitempty,gt=0,max=5000"`
+	// GeofencePolygon outlines the classroom; when set it replaces the radius check
+	GeofencePolygon []models.GeoPoint `json:"geofence_polygon" binding:"omitempty,min=3,dive"`
+	// RequireQR makes students scan the rotating QR code from /sessions/:id/qr
+	RequireQR bool `json:"require_qr"`
+}
+
+type EndSessionRequest struct {
+	SessionID string `json:"session_id" binding:"required"`
+	Action    string `json:"action" binding:"omitempty,oneof=complete cancel"`
+}
+
+// StartSession opens an attendance session for the signed-in teacher
+func StartSession(c *gin.Context) {
+	teacher := middleware.CurrentUser(c)
+
+	var req StartSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	countdown, ok := models.ParseCountdown(req.CountdownDuration)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid countdown duration"})
+		return
+	}
+	if !validGeofencePolygon(req.GeofencePolygon) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid geofence polygon coordinates"})
+		return
+	}
+
+	academicYear, err := services.ResolveSessionAudience(teacher.ID, req.SubjectID, req.SectionID, req.AcademicYear)
+	if err != nil {
+		respondCatalogueError(c, err)
+		return
+	}
+
+	var qrSecret string
+	if req.RequireQR {
+		if qrSecret, err = services.NewQRSecret(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+			return
+		}
+	}
+
+	var room *models.Room
+	if req.RoomID != "" {
+		room = &models.Room{}
+		if err := models.GetDB().Preload("AccessPoints").Where("id = ?", req.RoomID).First(room).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Room not found"})
+			return
+		}
+	} else if req.WifiSSID == "" || req.WifiBSSID == "" || req.LocationLat == nil || req.LocationLong == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wifi_ssid, wifi_bssid, location_lat and location_long are required without a room_id"})
+		return
+	}
+
+	var session models.AttendanceSession
+	var existing models.AttendanceSession
+	err = models.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := checkNoActiveSession(tx, teacher.ID, now, &existing); err != nil {
+			return err
+		}
+
+		session = models.AttendanceSession{
+			TeacherID:         teacher.ID,
+			SubjectID:         req.SubjectID,
+			SectionID:         req.SectionID,
+			AcademicYear:      academicYear,
+			StartTime:         now,
+			EndTime:           now.Add(countdown),
+			CountdownDuration: req.CountdownDuration,
+			Status:            models.SessionStatusActive,
+			WifiSSID:          req.WifiSSID,
+			WifiBSSID:         services.NormalizeBSSID(req.WifiBSSID),
+			QRRequired:        req.RequireQR,
+			QRSecret:          qrSecret,
+		}
+		if room != nil {
+			session.ApplyRoom(room)
+		} else {
+			session.LocationLat = *req.LocationLat
+			session.LocationLong = *req.LocationLong
+		}
+		if req.GeofenceRadius != nil {
+			session.GeofenceRadius = req.GeofenceRadius
+		}
+		if len(req.GeofencePolygon) > 0 {
+			if err := session.SetGeofencePolygon(req.GeofencePolygon); err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Teacher", "Room").Create(&session).Error
+	})
+	if err != nil {
+		if errors.Is(err, errSessionOverlap) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "You already have an active session. End it before starting a new one.",
+				"code":    ErrCodeSessionOverlap,
+				"session": sessionResponse(existing),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	publishSessionStarted(session, teacher)
+	c.JSON(http.StatusCreated, gin.H{"message": "Session started", "session": sessionResponse(session)})
+}
+
+// ConfirmSession opens a session the timetable scheduled for the teacher.
+// The countdown starts now.
+func ConfirmSession(c *gin.Context) {
+	teacher := middleware.CurrentUser(c)
+
+	var session models.AttendanceSession
+	var existing models.AttendanceSession
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := checkNoActiveSession(tx, teacher.ID, now, &existing); err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND teacher_id = ?", c.Param("id"), teacher.ID).First(&session).Error; err != nil {
+			return err
+		}
+		// A scheduled session stays confirmable until its class ends
+		if !session.IsScheduled() || !now.Before(session.EndTime) {
+			return errSessionNotScheduled
+		}
+
+		countdown, _ := models.ParseCountdown(session.CountdownDuration)
+		session.StartTime = now
+		session.EndTime = now.Add(countdown)
+		session.Status = models.SessionStatusActive
+		return tx.Omit(clause.Associations).Save(&session).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		case errors.Is(err, errSessionNotScheduled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Session is not waiting to be confirmed", "code": ErrCodeSessionNotScheduled, "session": sessionResponse(session)})
+		case errors.Is(err, errSessionOverlap):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "You already have an active session. End it before starting a new one.",
+				"code":    ErrCodeSessionOverlap,
+				"session": sessionResponse(existing),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		}
+		return
+	}
+
+	publishSessionStarted(session, teacher)
+	c.JSON(http.StatusOK, gin.H{"message": "Session started", "session": sessionResponse(session)})
+}
+
+// ActiveSessions lists the sessions that are open right now: the teacher's
+// own session, or the sessions a student is expected to attend
+func ActiveSessions(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	query := models.GetDB().Preload("Teacher").Preload("Room").
+		Where("status = ? AND end_time > ?", models.SessionStatusActive, time.Now())
+	if user.IsTeacher() {
+		query = query.Where("teacher_id = ?", user.ID)
+	} else {
+		query = services.StudentSessions(query, user)
+	}
+
+	var sessions []models.AttendanceSession
+	if err := query.Order("start_time desc").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load active sessions"})
+		return
+	}
+
+	render := studentSessionResponse
+	if user.IsTeacher() {
+		render = sessionResponse
+	}
+	result := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, render(session))
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": result, "server_time": time.Now()})
+}
+
+// EndSession completes or cancels one of the teacher's active sessions
+func EndSession(c *gin.Context) {
+	teacher := middleware.CurrentUser(c)
+
+	var req EndSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var session models.AttendanceSession
+	if err := db.Where("id = ? AND teacher_id = ?", req.SessionID, teacher.ID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if !session.IsActive() && !(session.IsScheduled() && req.Action == "cancel") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Session has already ended", "code": ErrCodeSessionNotActive, "session": sessionResponse(session)})
+		return
+	}
+
+	status := models.SessionStatusComplete
+	if req.Action == "cancel" {
+		status = models.SessionStatusCanceled
+	}
+
+	closed, err := services.CloseSession(session.ID, status, time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotActive) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Session has already ended", "code": ErrCodeSessionNotActive})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session ended", "session": sessionResponse(*closed)})
+}
+
+// SessionQRCode returns the current rotating QR token for one of the teacher's
+// QR mode sessions. The teacher's screen polls it and redraws the code.
+func SessionQRCode(c *gin.Context) {
+	teacher := middleware.CurrentUser(c)
+
+	var session models.AttendanceSession
+	if err := models.GetDB().Where("id = ? AND teacher_id = ?", c.Param("id"), teacher.ID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if !session.QRRequired {
+		c.JSON(http.StatusConflict, gin.H{"error": "QR verification is not enabled for this session", "code": ErrCodeQRNotEnabled})
+		return
+	}
+	if !session.IsOpen() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Session has already ended", "code": ErrCodeSessionNotActive})
+		return
+	}
+
+	now := time.Now()
+	qr := services.CurrentQRCode(&session, now)
+	c.JSON(http.StatusOK, gin.H{
+		"token":              qr.Token,
+		"step":               qr.Step,
+		"expires_at":         qr.ExpiresAt,
+		"refresh_in_seconds": math.Max(0, qr.ExpiresAt.Sub(now).Seconds()),
+	})
+}
+
+// checkNoActiveSession locks the teacher's row, so concurrent starts are
+// serialised, and returns errSessionOverlap with the session in existing if
+// the teacher already has one open
+func checkNoActiveSession(tx *gorm.DB, teacherID string, now time.Time, existing *models.AttendanceSession) error {
+	var locked models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", teacherID).First(&locked).Error; err != nil {
+		return err
+	}
+
+	// Sessions whose countdown has run out are closed by the scheduler
+	err := tx.Where("teacher_id = ? AND status = ? AND end_time > ?", teacherID, models.SessionStatusActive, now).
+		First(existing).Error
+	if err == nil {
+		return errSessionOverlap
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+func publishSessionStarted(session models.AttendanceSession, teacher *models.User) {
+	services.PublishEvent(services.EventSessionStarted, services.SessionStartedEvent{
+		SessionID:    session.ID,
+		TeacherID:    teacher.ID,
+		TeacherName:  teacher.FullName,
+		SubjectID:    session.SubjectID,
+		AcademicYear: session.AcademicYear,
+		SectionID:    session.SectionID,
+		RoomID:       session.RoomID,
+		QRRequired:   session.QRRequired,
+		StartTime:    session.StartTime,
+		EndTime:      session.EndTime,
+	})
+}
+
+// validGeofencePolygon reports whether every polygon vertex is a valid coordinate
+func validGeofencePolygon(points []models.GeoPoint) bool {
+	for _, point := range points {
+		if point.Lat < -90 || point.Lat > 90 || point.Lng < -180 || point.Lng > 180 {
+			return false
+		}
+	}
+	return true
+}
+
+// sessionResponse renders a session with its remaining countdown for the
+// teacher who runs it, including the values marks are verified against
+func sessionResponse(session models.AttendanceSession) gin.H {
+	resp := studentSessionResponse(session)
+	resp["wifi_bssid"] = session.WifiBSSID
+	resp["location_lat"] = session.LocationLat
+	resp["location_long"] = session.LocationLong
+	if session.GeofenceRadius != nil {
+		resp["geofence_radius_meters"] = *session.GeofenceRadius
+	}
+	if polygon, err := session.GetGeofencePolygon(); err == nil && len(polygon) > 0 {
+		resp["geofence_polygon"] = polygon
+	}
+	return resp
+}
+
+// studentSessionResponse renders a session for students. The BSSID,
+// coordinates and geofence are left out so they cannot be replayed in a mark.
+func studentSessionResponse(session models.AttendanceSession) gin.H {
+	resp := gin.H{
+		"id":                 session.ID,
+		"teacher_id":         session.TeacherID,
+		"subject_id":         session.SubjectID,
+		"academic_year":      session.AcademicYear,
+		"section_id":         session.SectionID,
+		"start_time":         session.StartTime,
+		"end_time":           session.EndTime,
+		"countdown_duration": session.CountdownDuration,
+		"status":             session.Status,
+		"wifi_ssid":          session.WifiSSID,
+		"qr_required":        session.QRRequired,
+		"timetable_entry_id": session.TimetableEntryID,
+		"remaining_seconds":  int(math.Ceil(session.RemainingTime().Seconds())),
+	}
+	if session.RoomID != nil {
+		resp["room_id"] = *session.RoomID
+	}
+	if session.Room != nil {
+		resp["room_name"] = session.Room.Name
+	}
+	if session.Teacher.ID != "" {
+		resp["teacher_name"] = session.Teacher.FullName
+	}
+	return resp
+}
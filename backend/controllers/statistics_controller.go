@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// MyAttendanceStats returns the signed-in student's attendance per subject.
+// The range is the term_id term, the from and to dates, or by default the
+// current term.
+func MyAttendanceStats(c *gin.Context) {
+	student := middleware.CurrentUser(c)
+	filter := services.StatsFilter{StudentID: student.ID}
+	if !applyStatsRange(c, &filter) {
+		return
+	}
+
+	policy := services.CurrentStatsPolicy()
+	summaries, err := services.AttendanceStats(filter, services.StatsBySubject, policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendance statistics"})
+		return
+	}
+
+	subjectIDs := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		subjectIDs = append(subjectIDs, summary.SubjectID)
+	}
+	var subjects []models.Subject
+	models.GetDB().Where("id IN ?", subjectIDs).Find(&subjects)
+	names := make(map[string]models.Subject, len(subjects))
+	for _, subject := range subjects {
+		names[subject.ID] = subject
+	}
+
+	result := make([]gin.H, 0, len(summaries))
+	for _, summary := range summaries {
+		subject := names[summary.SubjectID]
+		result = append(result, gin.H{
+			"subject_id":        summary.SubjectID,
+			"subject_code":      subject.Code,
+			"subject_name":      subject.Name,
+			"sessions":          summary.Sessions,
+			"present":           summary.Present,
+			"late":              summary.Late,
+			"manually_marked":   summary.ManuallyMarked,
+			"absent":            summary.Absent,
+			"excused":           summary.Excused,
+			"percentage":        summary.Percentage,
+			"excluded_sessions": summary.ExcludedSessions,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"subjects": result, "policy": policy, "from": statsDate(filter.From), "to": statsDate(filter.To)})
+}
+
+// SubjectAttendanceStats returns each student's attendance in one of the
+// signed-in teacher's subjects, optionally for one section_id
+func SubjectAttendanceStats(c *gin.Context) {
+	teacher := middleware.CurrentUser(c)
+	filter := services.StatsFilter{SubjectID: c.Param("id")}
+	if sectionID := c.Query("section_id"); sectionID != "" {
+		filter.SectionID = &sectionID
+	}
+	if _, _, err := services.CheckTeachingAssignment(teacher.ID, filter.SubjectID, filter.SectionID); err != nil {
+		respondCatalogueError(c, err)
+		return
+	}
+	if !applyStatsRange(c, &filter) {
+		return
+	}
+
+	policy := services.CurrentStatsPolicy()
+	summaries, err := services.AttendanceStats(filter, services.StatsByStudent, policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendance statistics"})
+		return
+	}
+
+	studentIDs := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		studentIDs = append(studentIDs, summary.StudentID)
+	}
+	var students []models.User
+	models.GetDB().Where("id IN ?", studentIDs).Find(&students)
+	byID := make(map[string]models.User, len(students))
+	for _, student := range students {
+		byID[student.ID] = student
+	}
+
+	result := make([]gin.H, 0, len(summaries))
+	for _, summary := range summaries {
+		student := byID[summary.StudentID]
+		result = append(result, gin.H{
+			"student_id":        summary.StudentID,
+			"full_name":         student.FullName,
+			"roll_number":       student.RollNumber,
+			"sessions":          summary.Sessions,
+			"present":           summary.Present,
+			"late":              summary.Late,
+			"manually_marked":   summary.ManuallyMarked,
+			"absent":            summary.Absent,
+			"excused":           summary.Excused,
+			"percentage":        summary.Percentage,
+			"excluded_sessions": summary.ExcludedSessions,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"students": result, "policy": policy, "from": statsDate(filter.From), "to": statsDate(filter.To)})
+}
+
+// applyStatsRange sets the filter's dates from term_id, or from and to. With
+// neither it uses the term running today, if there is one.
+func applyStatsRange(c *gin.Context, filter *services.StatsFilter) bool {
+	db := models.GetDB()
+	if termID := c.Query("term_id"); termID != "" {
+		var term models.Term
+		if err := db.Where("id = ?", termID).First(&term).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Term not found"})
+			return false
+		}
+		filter.From, filter.To = term.StartsOn, term.EndsOn
+		return true
+	}
+
+	if c.Query("from") != "" || c.Query("to") != "" {
+		for _, param := range []struct {
+			name  string
+			value *time.Time
+		}{{"from", &filter.From}, {"to", &filter.To}} {
+			if raw := c.Query(param.name); raw != "" {
+				parsed, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": param.name + " must be YYYY-MM-DD"})
+					return false
+				}
+				*param.value = parsed
+			}
+		}
+		return true
+	}
+
+	today := time.Now().Format("2006-01-02")
+	var term models.Term
+	if err := db.Where("starts_on <= ? AND ends_on >= ?", today, today).Order("starts_on desc").First(&term).Error; err == nil {
+		filter.From, filter.To = term.StartsOn, term.EndsOn
+	}
+	return true
+}
+
+func statsDate(date time.Time) *string {
+	if date.IsZero() {
+		return nil
+	}
+	formatted := date.Format("2006-01-02")
+	return &formatted
+}
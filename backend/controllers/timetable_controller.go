@@ -0,0 +1,499 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// Error codes returned by the timetable endpoints
+const (
+	ErrCodeTimetableClash = "timetable_clash"
+	ErrCodeDuplicateDate  = "duplicate_date"
+)
+
+// maxUpcomingDays bounds how far ahead the upcoming classes endpoint looks
+const maxUpcomingDays = 31
+
+type TermRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	StartsOn string `json:"starts_on" binding:"required,datetime=2006-01-02"`
+	EndsOn   string `json:"ends_on" binding:"required,datetime=2006-01-02"`
+}
+
+// TimetableEntryRequest describes a recurring class. The subject must be
+// assigned to the teacher, as when starting a session. Auto-open classes
+// need a room to take the session's location and Wi-Fi from.
+type TimetableEntryRequest struct {
+	TeacherID         string  `json:"teacher_id" binding:"required"`
+	SubjectID         string  `json:"subject_id" binding:"required"`
+	SectionID         *string `json:"section_id"`
+	AcademicYear      string  `json:"academic_year"`
+	RoomID            *string `json:"room_id"`
+	TermID            string  `json:"term_id" binding:"required"`
+	Weekday           *int    `json:"weekday" binding:"required,min=0,max=6"`
+	StartsAt          string  `json:"starts_at" binding:"required"`
+	EndsAt            string  `json:"ends_at" binding:"required"`
+	RepeatEveryWeeks  int     `json:"repeat_every_weeks" binding:"omitempty,min=1,max=4"`
+	ValidFrom         *string `json:"valid_from" binding:"omitempty,datetime=2006-01-02"`
+	ValidUntil        *string `json:"valid_until" binding:"omitempty,datetime=2006-01-02"`
+	AutoOpen          bool    `json:"auto_open"`
+	CountdownDuration string  `json:"countdown_duration" binding:"omitempty,oneof=30s 1m 3m"`
+	RequireQR         bool    `json:"require_qr"`
+}
+
+type TimetableExceptionRequest struct {
+	Date   string  `json:"date" binding:"required,datetime=2006-01-02"`
+	Reason *string `json:"reason" binding:"omitempty,max=255"`
+}
+
+// ListTerms returns every term
+func ListTerms(c *gin.Context) {
+	var terms []models.Term
+	if err := models.GetDB().Order("starts_on desc").Find(&terms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load terms"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"terms": terms})
+}
+
+// CreateTerm registers a term
+func CreateTerm(c *gin.Context) {
+	var req TermRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	if nameTaken(db.Model(&models.Term{}), req.Name, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Term already exists", "code": ErrCodeDuplicateName})
+		return
+	}
+
+	var term models.Term
+	if !applyTermRequest(c, &term, req) {
+		return
+	}
+	if err := db.Create(&term).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create term"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_term", gin.H{"term_id": term.ID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Term created", "term": term})
+}
+
+// UpdateTerm replaces a term's name and dates
+func UpdateTerm(c *gin.Context) {
+	var req TermRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var term models.Term
+	if err := db.Where("id = ?", c.Param("id")).First(&term).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Term not found"})
+		return
+	}
+	if nameTaken(db.Model(&models.Term{}), req.Name, term.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Term already exists", "code": ErrCodeDuplicateName})
+		return
+	}
+
+	if !applyTermRequest(c, &term, req) {
+		return
+	}
+	if err := db.Save(&term).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update term"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_term", gin.H{"term_id": term.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Term updated", "term": term})
+}
+
+// DeleteTerm removes a term with no timetable entries
+func DeleteTerm(c *gin.Context) {
+	db := models.GetDB()
+	var term models.Term
+	if err := db.Where("id = ?", c.Param("id")).First(&term).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Term not found"})
+		return
+	}
+
+	var entries int64
+	db.Model(&models.TimetableEntry{}).Where("term_id = ?", term.ID).Count(&entries)
+	if entries > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Remove the term's timetable entries first", "code": ErrCodeInUse})
+		return
+	}
+
+	if err := db.Delete(&term).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete term"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_term", gin.H{"term_id": term.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Term deleted"})
+}
+
+// ListTimetable returns timetable entries, optionally filtered by
+// teacher_id, section_id, room_id and term_id
+func ListTimetable(c *gin.Context) {
+	query := timetableQuery()
+	for _, filter := range []string{"teacher_id", "section_id", "room_id", "term_id"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where(filter+" = ?", value)
+		}
+	}
+
+	var entries []models.TimetableEntry
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load timetable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// MyTimetable returns the signed-in teacher's timetable entries
+func MyTimetable(c *gin.Context) {
+	teacher := middleware.CurrentUser(c)
+	var entries []models.TimetableEntry
+	if err := timetableQuery().Where("teacher_id = ?", teacher.ID).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load timetable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// UpcomingClasses returns the signed-in teacher's classes for the next
+// `days` days (default 7), with any session already opened for each
+func UpcomingClasses(c *gin.Context) {
+	teacher := middleware.CurrentUser(c)
+
+	days := 7
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxUpcomingDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxUpcomingDays)})
+			return
+		}
+		days = parsed
+	}
+
+	now := time.Now()
+	classes, err := services.UpcomingClasses(teacher.ID, now, now.AddDate(0, 0, days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load upcoming classes"})
+		return
+	}
+	if classes == nil {
+		classes = []services.ClassOccurrence{}
+	}
+	c.JSON(http.StatusOK, gin.H{"classes": classes, "server_time": now})
+}
+
+// CreateTimetableEntry adds a recurring class to the timetable
+func CreateTimetableEntry(c *gin.Context) {
+	var req TimetableEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry := models.TimetableEntry{}
+	if !applyTimetableEntryRequest(c, &entry, req) {
+		return
+	}
+	if err := models.GetDB().Omit(clause.Associations).Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create timetable entry"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "create_timetable_entry", gin.H{"entry_id": entry.ID, "teacher_id": entry.TeacherID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Timetable entry created", "entry": entry})
+}
+
+// UpdateTimetableEntry replaces a timetable entry. Sessions already opened
+// for it are left as they are.
+func UpdateTimetableEntry(c *gin.Context) {
+	var req TimetableEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var entry models.TimetableEntry
+	if err := db.Where("id = ?", c.Param("id")).First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timetable entry not found"})
+		return
+	}
+	if !applyTimetableEntryRequest(c, &entry, req) {
+		return
+	}
+	if err := db.Omit(clause.Associations).Save(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update timetable entry"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "update_timetable_entry", gin.H{"entry_id": entry.ID, "teacher_id": entry.TeacherID})
+	c.JSON(http.StatusOK, gin.H{"message": "Timetable entry updated", "entry": entry})
+}
+
+// DeleteTimetableEntry removes a timetable entry and cancels the sessions
+// still waiting to be confirmed for it
+func DeleteTimetableEntry(c *gin.Context) {
+	db := models.GetDB()
+	var entry models.TimetableEntry
+	if err := db.Where("id = ?", c.Param("id")).First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timetable entry not found"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AttendanceSession{}).
+			Where("timetable_entry_id = ? AND status = ?", entry.ID, models.SessionStatusScheduled).
+			Update("status", models.SessionStatusCanceled).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("entry_id = ?", entry.ID).Delete(&models.TimetableException{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entry).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete timetable entry"})
+		return
+	}
+
+	recordAdminAction(c, middleware.CurrentAdmin(c), "delete_timetable_entry", gin.H{"entry_id": entry.ID, "teacher_id": entry.TeacherID})
+	c.JSON(http.StatusOK, gin.H{"message": "Timetable entry deleted"})
+}
+
+// AddTimetableException skips one date of a timetable entry, e.g. for a
+// holiday. Teachers may skip classes of their own entries. A session still
+// waiting to be confirmed for that date is cancelled.
+func AddTimetableException(c *gin.Context) {
+	var req TimetableExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, ok := loadTimetableEntry(c)
+	if !ok {
+		return
+	}
+	date, _ := time.ParseInLocation("2006-01-02", req.Date, time.Local)
+
+	exception := models.TimetableException{EntryID: entry.ID, Date: date, Reason: req.Reason}
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&exception).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.AttendanceSession{}).
+			Where("timetable_entry_id = ? AND status = ?", entry.ID, models.SessionStatusScheduled).
+			Where("scheduled_for >= ? AND scheduled_for < ?", date, date.AddDate(0, 0, 1)).
+			Update("status", models.SessionStatusCanceled).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This date is already skipped", "code": ErrCodeDuplicateDate})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to skip class"})
+		return
+	}
+
+	if admin := middleware.CurrentAdmin(c); admin != nil {
+		recordAdminAction(c, admin, "add_timetable_exception", gin.H{"entry_id": entry.ID, "date": req.Date})
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Class skipped", "exception": exception})
+}
+
+// RemoveTimetableException restores a skipped date of a timetable entry
+func RemoveTimetableException(c *gin.Context) {
+	entry, ok := loadTimetableEntry(c)
+	if !ok {
+		return
+	}
+
+	result := models.GetDB().Where("id = ? AND entry_id = ?", c.Param("exception_id"), entry.ID).
+		Delete(&models.TimetableException{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore class"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exception not found"})
+		return
+	}
+
+	if admin := middleware.CurrentAdmin(c); admin != nil {
+		recordAdminAction(c, admin, "remove_timetable_exception", gin.H{"entry_id": entry.ID, "exception_id": c.Param("exception_id")})
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Class restored"})
+}
+
+func timetableQuery() *gorm.DB {
+	return models.GetDB().Preload("Subject").Preload("Section").Preload("Room").Preload("Term").
+		Preload("Exceptions", func(db *gorm.DB) *gorm.DB { return db.Order("date") }).
+		Order("weekday, starts_at")
+}
+
+// loadTimetableEntry loads the :id entry, restricted to the signed-in
+// teacher's own entries when a teacher is calling
+func loadTimetableEntry(c *gin.Context) (*models.TimetableEntry, bool) {
+	query := models.GetDB().Where("id = ?", c.Param("id"))
+	if user := middleware.CurrentUser(c); user != nil {
+		query = query.Where("teacher_id = ?", user.ID)
+	}
+
+	var entry models.TimetableEntry
+	if err := query.First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timetable entry not found"})
+		return nil, false
+	}
+	return &entry, true
+}
+
+func applyTermRequest(c *gin.Context, term *models.Term, req TermRequest) bool {
+	startsOn, _ := time.ParseInLocation("2006-01-02", req.StartsOn, time.Local)
+	endsOn, _ := time.ParseInLocation("2006-01-02", req.EndsOn, time.Local)
+	if endsOn.Before(startsOn) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_on must not be before starts_on"})
+		return false
+	}
+	term.Name = req.Name
+	term.StartsOn = startsOn
+	term.EndsOn = endsOn
+	return true
+}
+
+func applyTimetableEntryRequest(c *gin.Context, entry *models.TimetableEntry, req TimetableEntryRequest) bool {
+	startMinute, ok := services.ParseClock(req.StartsAt)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "starts_at must be HH:MM"})
+		return false
+	}
+	endMinute, ok := services.ParseClock(req.EndsAt)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be HH:MM"})
+		return false
+	}
+	if endMinute <= startMinute {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return false
+	}
+	validFrom, err := parseDate(req.ValidFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid valid_from"})
+		return false
+	}
+	validUntil, err := parseDate(req.ValidUntil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid valid_until"})
+		return false
+	}
+	if validFrom != nil && validUntil != nil && validUntil.Before(*validFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid_until must not be before valid_from"})
+		return false
+	}
+	if req.AutoOpen && req.RoomID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "room_id is required for auto_open classes"})
+		return false
+	}
+
+	db := models.GetDB()
+	var count int64
+	if db.Model(&models.User{}).Where("id = ? AND role = ?", req.TeacherID, models.RoleTeacher).Count(&count); count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Teacher not found"})
+		return false
+	}
+	if db.Model(&models.Term{}).Where("id = ?", req.TermID).Count(&count); count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Term not found"})
+		return false
+	}
+	if req.RoomID != nil {
+		if db.Model(&models.Room{}).Where("id = ?", *req.RoomID).Count(&count); count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Room not found"})
+			return false
+		}
+	}
+	academicYear, err := services.ResolveSessionAudience(req.TeacherID, req.SubjectID, req.SectionID, req.AcademicYear)
+	if err != nil {
+		respondCatalogueError(c, err)
+		return false
+	}
+
+	entry.TeacherID = req.TeacherID
+	entry.SubjectID = req.SubjectID
+	entry.SectionID = req.SectionID
+	entry.AcademicYear = academicYear
+	entry.RoomID = req.RoomID
+	entry.TermID = req.TermID
+	entry.Weekday = *req.Weekday
+	entry.StartsAt = fmt.Sprintf("%02d:%02d", startMinute/60, startMinute%60)
+	entry.EndsAt = fmt.Sprintf("%02d:%02d", endMinute/60, endMinute%60)
+	entry.RepeatEveryWeeks = req.RepeatEveryWeeks
+	if entry.RepeatEveryWeeks == 0 {
+		entry.RepeatEveryWeeks = 1
+	}
+	entry.ValidFrom = validFrom
+	entry.ValidUntil = validUntil
+	entry.AutoOpen = req.AutoOpen
+	entry.CountdownDuration = req.CountdownDuration
+	if entry.CountdownDuration == "" {
+		entry.CountdownDuration = "3m"
+	}
+	entry.RequireQR = req.RequireQR
+
+	if clash := timetableClash(entry); clash != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "The " + clash + " already has a class at this time", "code": ErrCodeTimetableClash})
+		return false
+	}
+	return true
+}
+
+// timetableClash returns which of the entry's teacher, room or section is
+// already booked on the same weekday of the term at an overlapping time
+func timetableClash(entry *models.TimetableEntry) string {
+	overlapping := func() *gorm.DB {
+		query := models.GetDB().Model(&models.TimetableEntry{}).
+			Where("term_id = ? AND weekday = ? AND starts_at < ? AND ends_at > ?", entry.TermID, entry.Weekday, entry.EndsAt, entry.StartsAt)
+		if entry.ID != "" {
+			query = query.Where("id <> ?", entry.ID)
+		}
+		return query
+	}
+
+	var count int64
+	if overlapping().Where("teacher_id = ?", entry.TeacherID).Count(&count); count > 0 {
+		return "teacher"
+	}
+	if entry.RoomID != nil {
+		if overlapping().Where("room_id = ?", *entry.RoomID).Count(&count); count > 0 {
+			return "room"
+		}
+	}
+	if entry.SectionID != nil {
+		if overlapping().Where("section_id = ?", *entry.SectionID).Count(&count); count > 0 {
+			return "section"
+		}
+	}
+	return ""
+}
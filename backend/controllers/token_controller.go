@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"smart_attendance_backend/middleware"
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// Error codes returned by the refresh token endpoints
+const (
+	ErrCodeRefreshTokenInvalid = "refresh_token_invalid"
+	ErrCodeRefreshTokenExpired = "refresh_token_expired"
+	ErrCodeRefreshTokenReused  = "refresh_token_reused"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken rotates a refresh token and returns a new token pair
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pair, user, err := services.RotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used. Please log in again.", "code": ErrCodeRefreshTokenReused})
+		case errors.Is(err, services.ErrRefreshTokenExpired):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has expired. Please log in again.", "code": ErrCodeRefreshTokenExpired})
+		case errors.Is(err, services.ErrRefreshTokenInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token", "code": ErrCodeRefreshTokenInvalid})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+
+	if resp, blocked := middleware.AccountStatusError(*user); blocked {
+		c.JSON(http.StatusForbidden, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Token refreshed",
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+	})
+}
+
+// Logout revokes the refresh token family of the current login
+func Logout(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.RevokeRefreshToken(req.RefreshToken); err != nil {
+		if errors.Is(err, services.ErrRefreshTokenInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token", "code": ErrCodeRefreshTokenInvalid})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// ListLogins returns the current user's active logins
+func ListLogins(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	claims := middleware.CurrentClaims(c)
+
+	logins, err := services.ActiveLogins(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load active logins"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(logins))
+	for _, login := range logins {
+		result = append(result, gin.H{
+			"id":           login.ID,
+			"user_agent":   login.UserAgent,
+			"ip_address":   login.IPAddress,
+			"created_at":   login.CreatedAt,
+			"last_used_at": login.LastUsedAt,
+			"current":      claims != nil && claims.SessionID == login.ID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
+
+// RevokeLogin revokes one of the current user's logins
+func RevokeLogin(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	if err := services.RevokeLogin(user.ID, c.Param("id"), "user_revoked"); err != nil {
+		if errors.Is(err, services.ErrLoginNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Login session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke login session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login session revoked"})
+}
+
+// LogoutAll revokes every login of the current user, including this one
+func LogoutAll(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	if err := services.RevokeAllLogins(models.GetDB(), user.ID, "logout_all"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out all devices"})
+		return
+	}
+
+	audit := models.AuditLog{UserID: user.ID, Action: "logout_all_devices", IPAddress: c.ClientIP()}
+	models.GetDB().Create(&audit)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all devices"})
+}
+
+// JWKS publishes the public keys that verify access tokens
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, services.JWKS())
+}
@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/services"
+)
+
+// Context keys set by AuthRequired and AdminRequired
+const (
+	ContextUserKey   = "user"
+	ContextAdminKey  = "admin"
+	ContextClaimsKey = "claims"
+)
+
+// Error codes returned with authentication and account status errors so
+// clients can route users to the right screen
+const (
+	ErrCodeUnauthorized               = "unauthorized"
+	ErrCodeTokenExpired               = "token_expired"
+	ErrCodeTokenRevoked               = "token_revoked"
+	ErrCodeForbidden                  = "forbidden"
+	ErrCodeAccountPendingVerification = "account_pending_verification"
+	ErrCodeAccountSuspended           = "account_suspended"
+	ErrCodeAccountDeactivated         = "account_deactivated"
+)
+
+// AccountStatusError builds the response for a user whose account status
+// does not allow signing in. The second return value is false for active users.
+func AccountStatusError(user models.User) (gin.H, bool) {
+	switch user.Status {
+	case models.UserStatusActive:
+		return nil, false
+	case models.UserStatusPendingVerification:
+		return gin.H{
+			"error":   "Account is not verified. Please verify the OTP sent to your phone.",
+			"code":    ErrCodeAccountPendingVerification,
+			"user_id": user.ID,
+		}, true
+	case models.UserStatusSuspended:
+		return gin.H{"error": "Account has been suspended. Please contact your administrator.", "code": ErrCodeAccountSuspended}, true
+	default:
+		return gin.H{"error": "Account has been deactivated.", "code": ErrCodeAccountDeactivated}, true
+	}
+}
+
+// AuthRequired validates the bearer token and loads the user into the context
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := parseClaims(c)
+		if !ok {
+			return
+		}
+		if claims.Role == services.AdminTokenRole {
+			abortUnauthorized(c, "Invalid token", ErrCodeUnauthorized)
+			return
+		}
+
+		var user models.User
+		if err := models.GetDB().Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+			abortUnauthorized(c, "Invalid token", ErrCodeUnauthorized)
+			return
+		}
+
+		if string(user.Role) != claims.Role {
+			abortUnauthorized(c, "Invalid token", ErrCodeUnauthorized)
+			return
+		}
+
+		if claims.IsRevokedFor(user.TokenVersion) {
+			abortUnauthorized(c, "Token has been revoked", ErrCodeTokenRevoked)
+			return
+		}
+		if claims.SessionID != "" {
+			revoked, err := services.IsLoginRevoked(user.ID, claims.SessionID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+				return
+			}
+			if revoked {
+				abortUnauthorized(c, "Token has been revoked", ErrCodeTokenRevoked)
+				return
+			}
+		}
+
+		if resp, blocked := AccountStatusError(user); blocked {
+			c.AbortWithStatusJSON(http.StatusForbidden, resp)
+			return
+		}
+
+		c.Set(ContextClaimsKey, claims)
+		c.Set(ContextUserKey, &user)
+		c.Next()
+	}
+}
+
+// AdminRequired validates an admin portal token and loads the admin into the context
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := parseClaims(c)
+		if !ok {
+			return
+		}
+		if claims.Role != services.AdminTokenRole {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource", "code": ErrCodeForbidden})
+			return
+		}
+
+		var admin models.Admin
+		if err := models.GetDB().Where("id = ?", claims.UserID).First(&admin).Error; err != nil {
+			abortUnauthorized(c, "Invalid token", ErrCodeUnauthorized)
+			return
+		}
+
+		if claims.IsRevokedFor(admin.TokenVersion) {
+			abortUnauthorized(c, "Token has been revoked", ErrCodeTokenRevoked)
+			return
+		}
+
+		c.Set(ContextClaimsKey, claims)
+		c.Set(ContextAdminKey, &admin)
+		c.Next()
+	}
+}
+
+// RequireRole only lets users with one of the given roles through. It must run
+// after AuthRequired.
+func RequireRole(roles ...models.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		if user == nil {
+			abortUnauthorized(c, "Authorization token required", ErrCodeUnauthorized)
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource", "code": ErrCodeForbidden})
+	}
+}
+
+// CurrentUser returns the authenticated user, or nil if AuthRequired did not run
+func CurrentUser(c *gin.Context) *models.User {
+	value, exists := c.Get(ContextUserKey)
+	if !exists {
+		return nil
+	}
+	user, _ := value.(*models.User)
+	return user
+}
+
+// CurrentAdmin returns the authenticated admin, or nil if AdminRequired did not run
+func CurrentAdmin(c *gin.Context) *models.Admin {
+	value, exists := c.Get(ContextAdminKey)
+	if !exists {
+		return nil
+	}
+	admin, _ := value.(*models.Admin)
+	return admin
+}
+
+// CurrentClaims returns the validated token claims, or nil if AuthRequired did not run
+func CurrentClaims(c *gin.Context) *services.Claims {
+	value, exists := c.Get(ContextClaimsKey)
+	if !exists {
+		return nil
+	}
+	claims, _ := value.(*services.Claims)
+	return claims
+}
+
+// parseClaims validates the bearer token, aborting the request if it is missing or invalid
+func parseClaims(c *gin.Context) (*services.Claims, bool) {
+	tokenString := bearerToken(c)
+	if tokenString == "" {
+		abortUnauthorized(c, "Authorization token required", ErrCodeUnauthorized)
+		return nil, false
+	}
+
+	claims, err := services.ParseToken(tokenString)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			abortUnauthorized(c, "Token has expired", ErrCodeTokenExpired)
+			return nil, false
+		}
+		abortUnauthorized(c, "Invalid token", ErrCodeUnauthorized)
+		return nil, false
+	}
+	return claims, true
+}
+
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+func abortUnauthorized(c *gin.Context, message, code string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message, "code": code})
+}
+
+// QueryToken lets a request authenticate with an access_token query parameter
+// when it has no Authorization header. Browsers' EventSource cannot set
+// headers, so it is only meant for streaming endpoints.
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Next()
+	}
+}
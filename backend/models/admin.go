@@ -22,6 +22,7 @@ type Admin struct {
 	Email        string         `gorm:"type:varchar(255);unique;not null" json:"email"`
 	PasswordHash string         `gorm:"type:varchar(255);not null" json:"-"`
 	Role         AdminRole      `gorm:"type:enum('super_admin','moderator');not null" json:"role"`
+	TokenVersion int            `gorm:"default:0;not null" json:"-"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
@@ -55,6 +56,15 @@ func (a *AdminAuditLog) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+func (a *AdminAuditLog) SetDetails(details interface{}) error {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	a.Details = data
+	return nil
+}
+
 type Report struct {
 	ID          string          `gorm:"type:varchar(36);primary_key" json:"id"`
 	ReportType  string          `gorm:"type:enum('attendance','user_activity','security');not null" json:"report_type"`
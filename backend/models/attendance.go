@@ -15,24 +15,77 @@ const (
 	VerificationMethodLocation VerificationMethod = "location"
 	VerificationMethodWifi     VerificationMethod = "wifi"
 	VerificationMethodBoth     VerificationMethod = "both"
+	// The _qr methods are used when a rotating QR token was also verified
+	VerificationMethodLocationQR VerificationMethod = "location_qr"
+	VerificationMethodWifiQR     VerificationMethod = "wifi_qr"
+	VerificationMethodBothQR     VerificationMethod = "both_qr"
+	// VerificationMethodNone is used for records the server creates itself,
+	// such as absences finalized when a session closes
+	VerificationMethodNone VerificationMethod = "none"
 )
 
+// WithQR returns the method that also records a verified QR token.
+func (m VerificationMethod) WithQR() VerificationMethod {
+	switch m {
+	case VerificationMethodLocation:
+		return VerificationMethodLocationQR
+	case VerificationMethodWifi:
+		return VerificationMethodWifiQR
+	case VerificationMethodBoth:
+		return VerificationMethodBothQR
+	}
+	return m
+}
+
+type AttendanceStatus string
+
+const (
+	AttendanceStatusPresent AttendanceStatus = "present"
+	// AttendanceStatusLate is a verified mark made after the subject's late
+	// threshold
+	AttendanceStatusLate    AttendanceStatus = "late"
+	AttendanceStatusAbsent  AttendanceStatus = "absent"
+	AttendanceStatusExcused AttendanceStatus = "excused"
+	// AttendanceStatusManual is present as recorded by the teacher rather
+	// than verified from the student's device
+	AttendanceStatusManual AttendanceStatus = "manually_marked"
+)
+
+// AttendedStatuses are the statuses that mean the student was in class
+var AttendedStatuses = []AttendanceStatus{AttendanceStatusPresent, AttendanceStatusLate, AttendanceStatusManual}
+
+// Attended reports whether the status means the student was in class
+func (s AttendanceStatus) Attended() bool {
+	for _, status := range AttendedStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 type AttendanceRecord struct {
 	ID                 string             `gorm:"type:varchar(36);primary_key" json:"id"`
-	SessionID          string             `gorm:"type:varchar(36);not null" json:"session_id"`
+	SessionID          string             `gorm:"type:varchar(36);not null;uniqueIndex:idx_attendance_session_student" json:"session_id"`
 	Session            AttendanceSession  `gorm:"foreignKey:SessionID" json:"session"`
-	StudentID          string             `gorm:"type:varchar(36);not null" json:"student_id"`
+	StudentID          string             `gorm:"type:varchar(36);not null;uniqueIndex:idx_attendance_session_student" json:"student_id"`
 	Student            User               `gorm:"foreignKey:StudentID" json:"student"`
+	Status             AttendanceStatus   `gorm:"type:enum('present','late','absent','excused','manually_marked');default:'present';not null" json:"status"`
 	MarkedAt           time.Time          `gorm:"not null" json:"marked_at"`
-	VerificationMethod VerificationMethod `gorm:"type:enum('location','wifi','both');not null" json:"verification_method"`
+	VerificationMethod VerificationMethod `gorm:"type:enum('location','wifi','both','location_qr','wifi_qr','both_qr','none');not null" json:"verification_method"`
 	DeviceInfo         json.RawMessage    `gorm:"type:json" json:"device_info"`
 	LocationLat        *float64           `gorm:"type:decimal(10,8)" json:"location_lat,omitempty"`
 	LocationLong       *float64           `gorm:"type:decimal(11,8)" json:"location_long,omitempty"`
+	LocationAccuracy   *float64           `gorm:"type:decimal(8,2)" json:"location_accuracy,omitempty"`
+	DistanceMeters     *float64           `gorm:"type:decimal(10,2)" json:"distance_meters,omitempty"`
 	WifiSSID           *string            `gorm:"type:varchar(100)" json:"wifi_ssid,omitempty"`
 	WifiBSSID          *string            `gorm:"type:varchar(100)" json:"wifi_bssid,omitempty"`
-	CreatedAt          time.Time          `json:"created_at"`
-	UpdatedAt          time.Time          `json:"updated_at"`
-	DeletedAt          gorm.DeletedAt     `gorm:"index" json:"-"`
+	// MarkedByUserID is the teacher who last set the status by hand
+	MarkedByUserID *string        `gorm:"type:varchar(36)" json:"marked_by_user_id,omitempty"`
+	Note           *string        `gorm:"type:varchar(255)" json:"note,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (a *AttendanceRecord) BeforeCreate(tx *gorm.DB) error {
@@ -42,17 +95,22 @@ func (a *AttendanceRecord) BeforeCreate(tx *gorm.DB) error {
 	if a.MarkedAt.IsZero() {
 		a.MarkedAt = time.Now()
 	}
+	if a.Status == "" {
+		a.Status = AttendanceStatusPresent
+	}
 	return nil
 }
 
 // DeviceInfoStruct represents the structure of device information
 type DeviceInfoStruct struct {
-	DeviceID         string `json:"device_id"`
-	DeviceModel      string `json:"device_model"`
-	DeviceBrand      string `json:"device_brand"`
-	OSVersion        string `json:"os_version"`
-	DeveloperMode    bool   `json:"developer_mode"`
-	DeviceRegistered bool   `json:"device_registered"`
+	DeviceID      string `json:"device_id"`
+	DeviceModel   string `json:"device_model"`
+	DeviceBrand   string `json:"device_brand"`
+	OSVersion     string `json:"os_version"`
+	DeveloperMode bool   `json:"developer_mode"`
+	// DeviceRegistered is reported by the client; the server checks the
+	// device registry instead
+	DeviceRegistered bool `json:"device_registered"`
 }
 
 func (a *AttendanceRecord) SetDeviceInfo(info DeviceInfoStruct) error {
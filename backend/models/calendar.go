@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"smart_attendance_backend/utils"
+
+	"gorm.io/gorm"
+)
+
+type CalendarEventKind string
+
+const (
+	CalendarEventHoliday    CalendarEventKind = "holiday"
+	CalendarEventExamPeriod CalendarEventKind = "exam_period"
+	CalendarEventClosure    CalendarEventKind = "closure"
+)
+
+// CalendarEvent marks a run of non-teaching days on the academic calendar:
+// timetabled classes are not opened and sessions held on these days are left
+// out of attendance statistics. StartsOn and EndsOn are inclusive.
+type CalendarEvent struct {
+	ID          string            `gorm:"type:varchar(36);primary_key" json:"id"`
+	Kind        CalendarEventKind `gorm:"type:enum('holiday','exam_period','closure');not null" json:"kind"`
+	Title       string            `gorm:"type:varchar(255);not null" json:"title"`
+	Description *string           `gorm:"type:text" json:"description,omitempty"`
+	StartsOn    time.Time         `gorm:"type:date;not null;index" json:"starts_on"`
+	EndsOn      time.Time         `gorm:"type:date;not null;index" json:"ends_on"`
+	// UID is the iCalendar UID of an imported event, so importing the same
+	// calendar again updates it instead of adding a copy
+	UID       *string   `gorm:"type:varchar(255);uniqueIndex" json:"uid,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (e *CalendarEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+// Covers reports whether the event includes the calendar date of day
+func (e *CalendarEvent) Covers(day time.Time) bool {
+	date := day.Format("2006-01-02")
+	return date >= e.StartsOn.Format("2006-01-02") && date <= e.EndsOn.Format("2006-01-02")
+}
+
+// IsValidCalendarEventKind reports whether kind is a known event kind
+func IsValidCalendarEventKind(kind CalendarEventKind) bool {
+	switch kind {
+	case CalendarEventHoliday, CalendarEventExamPeriod, CalendarEventClosure:
+		return true
+	}
+	return false
+}
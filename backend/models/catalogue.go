@@ -0,0 +1,126 @@
+package models
+
+import (
+	"time"
+
+	"smart_attendance_backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// AcademicYear is a cohort year students register into. Its Name is what
+// User.AcademicYear and AttendanceSession.AcademicYear hold.
+type AcademicYear struct {
+	ID        string         `gorm:"type:varchar(36);primary_key" json:"id"`
+	Name      string         `gorm:"type:varchar(50);uniqueIndex;not null" json:"name"`
+	StartsOn  *time.Time     `gorm:"type:date" json:"starts_on,omitempty"`
+	EndsOn    *time.Time     `gorm:"type:date" json:"ends_on,omitempty"`
+	Active    bool           `gorm:"default:true;not null" json:"active"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (a *AcademicYear) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+// Course is a programme of study. Its Code is what User.Course holds.
+type Course struct {
+	ID        string         `gorm:"type:varchar(36);primary_key" json:"id"`
+	Code      string         `gorm:"type:varchar(50);uniqueIndex;not null" json:"code"`
+	Name      string         `gorm:"type:varchar(255);not null" json:"name"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (c *Course) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+// Subject is taught in attendance sessions. When CourseID is set only
+// students of that course are expected to attend.
+type Subject struct {
+	ID       string  `gorm:"type:varchar(36);primary_key" json:"id"`
+	Code     string  `gorm:"type:varchar(50);uniqueIndex;not null" json:"code"`
+	Name     string  `gorm:"type:varchar(255);not null" json:"name"`
+	CourseID *string `gorm:"type:varchar(36);index" json:"course_id,omitempty"`
+	Course   *Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	// LateAfterSeconds overrides how long after a class starts a mark
+	// counts as late
+	LateAfterSeconds *int           `json:"late_after_seconds,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (s *Subject) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+// Section is a class group of a course in an academic year, e.g. "BCA 2024 A".
+type Section struct {
+	ID             string         `gorm:"type:varchar(36);primary_key" json:"id"`
+	CourseID       string         `gorm:"type:varchar(36);not null;uniqueIndex:idx_sections_course_year_name" json:"course_id"`
+	Course         *Course        `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	AcademicYearID string         `gorm:"type:varchar(36);not null;uniqueIndex:idx_sections_course_year_name" json:"academic_year_id"`
+	AcademicYear   *AcademicYear  `gorm:"foreignKey:AcademicYearID" json:"academic_year,omitempty"`
+	Name           string         `gorm:"type:varchar(50);not null;uniqueIndex:idx_sections_course_year_name" json:"name"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (s *Section) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+// TeacherSubject assigns a subject to a teacher, either for every section or
+// for one section only.
+type TeacherSubject struct {
+	ID        string    `gorm:"type:varchar(36);primary_key" json:"id"`
+	TeacherID string    `gorm:"type:varchar(36);not null;index" json:"teacher_id"`
+	Teacher   *User     `gorm:"foreignKey:TeacherID" json:"teacher,omitempty"`
+	SubjectID string    `gorm:"type:varchar(36);not null;index" json:"subject_id"`
+	Subject   *Subject  `gorm:"foreignKey:SubjectID" json:"subject,omitempty"`
+	SectionID *string   `gorm:"type:varchar(36)" json:"section_id,omitempty"`
+	Section   *Section  `gorm:"foreignKey:SectionID" json:"section,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (t *TeacherSubject) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+// SectionEnrollment places a student in a section.
+type SectionEnrollment struct {
+	ID        string    `gorm:"type:varchar(36);primary_key" json:"id"`
+	SectionID string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_enrollments_section_student" json:"section_id"`
+	Section   *Section  `gorm:"foreignKey:SectionID" json:"section,omitempty"`
+	StudentID string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_enrollments_section_student;index" json:"student_id"`
+	Student   *User     `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (e *SectionEnrollment) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = utils.GenerateUUID()
+	}
+	return nil
+}
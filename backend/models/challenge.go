@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"smart_attendance_backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// AttendanceChallenge is a single-use nonce issued to a student's bound device
+// for one session. The device signs it together with the mark payload.
+type AttendanceChallenge struct {
+	ID        string     `gorm:"type:varchar(36);primary_key" json:"id"`
+	SessionID string     `gorm:"type:varchar(36);not null;index" json:"session_id"`
+	UserID    string     `gorm:"type:varchar(36);not null;index" json:"user_id"`
+	DeviceID  string     `gorm:"type:varchar(255);not null" json:"device_id"`
+	Nonce     string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"nonce"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (c *AttendanceChallenge) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+func (c *AttendanceChallenge) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+func (c *AttendanceChallenge) IsUsed() bool {
+	return c.UsedAt != nil
+}
@@ -16,6 +16,8 @@ func InitDB(dsn string) error {
 	// Configure GORM
 	config := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// Report unique index violations as gorm.ErrDuplicatedKey
+		TranslateError: true,
 	}
 
 	// Connect to database
@@ -26,6 +28,9 @@ func InitDB(dsn string) error {
 
 	log.Println("Connected to database")
 
+	// Checked before migrating, which adds the column
+	addingUserStatus := DB.Migrator().HasTable(&User{}) && !DB.Migrator().HasColumn(&User{}, "Status")
+
 	// Auto-migrate models
 	err = DB.AutoMigrate(
 		&User{},
@@ -36,15 +41,54 @@ func InitDB(dsn string) error {
 		&Admin{},
 		&AdminAuditLog{},
 		&Report{},
+		&RefreshTokenFamily{},
+		&RefreshToken{},
+		&SchedulerLock{},
+		&Campus{},
+		&Building{},
+		&Room{},
+		&RoomAccessPoint{},
+		&UserDevice{},
+		&DeviceRebindRequest{},
+		&AttendanceChallenge{},
+		&QRTokenUse{},
+		&RealtimeMessage{},
+		&AcademicYear{},
+		&Course{},
+		&Subject{},
+		&Section{},
+		&TeacherSubject{},
+		&SectionEnrollment{},
+		&Term{},
+		&TimetableEntry{},
+		&TimetableException{},
+		&CalendarEvent{},
 	)
 	if err != nil {
 		return err
 	}
 
+	if addingUserStatus {
+		if err := backfillUserStatus(DB); err != nil {
+			return err
+		}
+	}
+
 	log.Println("Database migration completed")
 	return nil
 }
 
+// backfillUserStatus activates users who were verified before accounts had
+// a status, since the new column defaults everyone to pending_verification
+func backfillUserStatus(db *gorm.DB) error {
+	result := db.Model(&User{}).Where("verified = ?", true).Update("status", UserStatusActive)
+	if result.Error != nil {
+		return result.Error
+	}
+	log.Printf("Activated %d verified users", result.RowsAffected)
+	return nil
+}
+
 func GetDB() *gorm.DB {
 	return DB
 }
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"smart_attendance_backend/utils"
+
+	"gorm.io/gorm"
+)
+
+type DeviceStatus string
+
+const (
+	DeviceStatusActive  DeviceStatus = "active"
+	DeviceStatusRevoked DeviceStatus = "revoked"
+)
+
+// UserDevice binds a student to the one device they may mark attendance from.
+// A user has at most one active device; earlier bindings are kept as revoked.
+type UserDevice struct {
+	ID          string       `gorm:"type:varchar(36);primary_key" json:"id"`
+	UserID      string       `gorm:"type:varchar(36);not null;index:idx_user_devices_user_status" json:"user_id"`
+	DeviceID    string       `gorm:"type:varchar(255);not null;index" json:"device_id"`
+	PublicKey   *string      `gorm:"type:text" json:"public_key,omitempty"`
+	DeviceModel *string      `gorm:"type:varchar(100)" json:"device_model,omitempty"`
+	DeviceBrand *string      `gorm:"type:varchar(100)" json:"device_brand,omitempty"`
+	OSVersion   *string      `gorm:"type:varchar(50)" json:"os_version,omitempty"`
+	Status      DeviceStatus `gorm:"type:enum('active','revoked');default:'active';index:idx_user_devices_user_status" json:"status"`
+	BoundAt     time.Time    `gorm:"not null" json:"bound_at"`
+	LastSeenAt  *time.Time   `json:"last_seen_at,omitempty"`
+	RevokedAt   *time.Time   `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+func (d *UserDevice) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = utils.GenerateUUID()
+	}
+	if d.Status == "" {
+		d.Status = DeviceStatusActive
+	}
+	if d.BoundAt.IsZero() {
+		d.BoundAt = time.Now()
+	}
+	return nil
+}
+
+func (d *UserDevice) IsActive() bool {
+	return d.Status == DeviceStatusActive
+}
+
+func (d *UserDevice) Revoke() {
+	now := time.Now()
+	d.Status = DeviceStatusRevoked
+	d.RevokedAt = &now
+}
+
+type RebindStatus string
+
+const (
+	RebindStatusPending   RebindStatus = "pending"
+	RebindStatusApproved  RebindStatus = "approved"
+	RebindStatusRejected  RebindStatus = "rejected"
+	RebindStatusCancelled RebindStatus = "cancelled"
+)
+
+// DeviceRebindRequest asks for a student's binding to move to a new device.
+// It is reviewed by a teacher or an admin.
+type DeviceRebindRequest struct {
+	ID                string       `gorm:"type:varchar(36);primary_key" json:"id"`
+	UserID            string       `gorm:"type:varchar(36);not null;index" json:"user_id"`
+	User              User         `gorm:"foreignKey:UserID" json:"user"`
+	CurrentDeviceID   *string      `gorm:"type:varchar(255)" json:"current_device_id,omitempty"`
+	NewDeviceID       string       `gorm:"type:varchar(255);not null" json:"new_device_id"`
+	NewPublicKey      *string      `gorm:"type:text" json:"new_public_key,omitempty"`
+	DeviceModel       *string      `gorm:"type:varchar(100)" json:"device_model,omitempty"`
+	DeviceBrand       *string      `gorm:"type:varchar(100)" json:"device_brand,omitempty"`
+	OSVersion         *string      `gorm:"type:varchar(50)" json:"os_version,omitempty"`
+	Reason            string       `gorm:"type:text;not null" json:"reason"`
+	Status            RebindStatus `gorm:"type:enum('pending','approved','rejected','cancelled');default:'pending';index" json:"status"`
+	ReviewedByUserID  *string      `gorm:"type:varchar(36)" json:"reviewed_by_user_id,omitempty"`
+	ReviewedByAdminID *string      `gorm:"type:varchar(36)" json:"reviewed_by_admin_id,omitempty"`
+	ReviewNote        *string      `gorm:"type:text" json:"review_note,omitempty"`
+	ReviewedAt        *time.Time   `json:"reviewed_at,omitempty"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+}
+
+func (r *DeviceRebindRequest) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = utils.GenerateUUID()
+	}
+	if r.Status == "" {
+		r.Status = RebindStatusPending
+	}
+	return nil
+}
+
+func (r *DeviceRebindRequest) IsPending() bool {
+	return r.Status == RebindStatusPending
+}
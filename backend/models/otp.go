@@ -8,17 +8,39 @@ import (
 	"gorm.io/gorm"
 )
 
+// OTPPurpose scopes a code to the flow it was issued for
+type OTPPurpose string
+
+const (
+	OTPPurposeRegistration    OTPPurpose = "registration"
+	OTPPurposePasswordReset   OTPPurpose = "password_reset"
+	OTPPurposePhoneChange     OTPPurpose = "phone_change"
+	OTPPurposeSensitiveAction OTPPurpose = "sensitive_action"
+)
+
+func (p OTPPurpose) IsValid() bool {
+	switch p {
+	case OTPPurposeRegistration, OTPPurposePasswordReset, OTPPurposePhoneChange, OTPPurposeSensitiveAction:
+		return true
+	}
+	return false
+}
+
+// OTPVerification tracks an outstanding one-time password. OTPCode holds an
+// HMAC of the code, never the code itself.
 type OTPVerification struct {
-	ID        string         `gorm:"type:varchar(36);primary_key" json:"id"`
-	UserID    string         `gorm:"type:varchar(36);not null" json:"user_id"`
-	User      User           `gorm:"foreignKey:UserID" json:"user"`
-	OTPCode   string         `gorm:"type:varchar(10);not null" json:"-"`
-	ExpiresAt time.Time      `gorm:"not null" json:"expires_at"`
-	Verified  bool           `gorm:"default:false" json:"verified"`
-	Attempts  int            `gorm:"default:0" json:"attempts"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            string         `gorm:"type:varchar(36);primary_key" json:"id"`
+	UserID        string         `gorm:"type:varchar(36);not null" json:"user_id"`
+	User          User           `gorm:"foreignKey:UserID" json:"user"`
+	Purpose       OTPPurpose     `gorm:"type:enum('registration','password_reset','phone_change','sensitive_action');default:'registration';not null" json:"purpose"`
+	OTPCode       string         `gorm:"type:varchar(64);not null" json:"-"`
+	ExpiresAt     time.Time      `gorm:"not null" json:"expires_at"`
+	Verified      bool           `gorm:"default:false" json:"verified"`
+	Attempts      int            `gorm:"default:0" json:"attempts"`
+	InvalidatedAt *time.Time     `json:"invalidated_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (o *OTPVerification) BeforeCreate(tx *gorm.DB) error {
@@ -43,3 +65,13 @@ func (o *OTPVerification) HasExceededMaxAttempts(maxAttempts int) bool {
 func (o *OTPVerification) MarkAsVerified() {
 	o.Verified = true
 }
+
+// Invalidate retires the code, e.g. because a newer one was issued
+func (o *OTPVerification) Invalidate() {
+	now := time.Now()
+	o.InvalidatedAt = &now
+}
+
+func (o *OTPVerification) IsInvalidated() bool {
+	return o.InvalidatedAt != nil
+}
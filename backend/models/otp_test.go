@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOTPVerificationLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		expiresIn    time.Duration
+		failures     int
+		maxAttempts  int
+		wantExpired  bool
+		wantExceeded bool
+	}{
+		{"fresh code", time.Minute, 0, 3, false, false},
+		{"one attempt left", time.Minute, 2, 3, false, false},
+		{"attempts used up", time.Minute, 3, 3, false, true},
+		{"beyond the limit", time.Minute, 5, 3, false, true},
+		{"no attempts allowed", time.Minute, 0, 0, false, true},
+		{"expired", -time.Second, 0, 3, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otp := OTPVerification{ExpiresAt: time.Now().Add(tt.expiresIn)}
+			for i := 0; i < tt.failures; i++ {
+				otp.IncrementAttempts()
+			}
+			if got := otp.IsExpired(); got != tt.wantExpired {
+				t.Errorf("IsExpired = %v, want %v", got, tt.wantExpired)
+			}
+			if got := otp.HasExceededMaxAttempts(tt.maxAttempts); got != tt.wantExceeded {
+				t.Errorf("HasExceededMaxAttempts(%d) = %v, want %v", tt.maxAttempts, got, tt.wantExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// QRTokenUse records that a student presented a session's QR token for a
+// rotation step, so the same token cannot be replayed in a later attempt.
+type QRTokenUse struct {
+	SessionID string    `gorm:"type:varchar(36);primary_key" json:"session_id"`
+	StudentID string    `gorm:"type:varchar(36);primary_key" json:"student_id"`
+	Step      int64     `gorm:"primary_key;autoIncrement:false" json:"step"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RealtimeMessage is a live event shared between server instances by the
+// database broker. Rows are ordered by ID and pruned once delivered.
+type RealtimeMessage struct {
+	ID        uint64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	Topic     string          `gorm:"type:varchar(150);not null" json:"topic"`
+	Type      string          `gorm:"type:varchar(50);not null" json:"type"`
+	Payload   json.RawMessage `gorm:"type:json" json:"payload"`
+	CreatedAt time.Time       `gorm:"index" json:"created_at"`
+}
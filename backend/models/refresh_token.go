@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"smart_attendance_backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// RefreshTokenFamily groups every refresh token issued from a single login.
+// Rotating a token keeps it in the same family, so replaying an old token can
+// revoke the whole chain at once.
+type RefreshTokenFamily struct {
+	ID           string     `gorm:"type:varchar(36);primary_key" json:"id"`
+	UserID       string     `gorm:"type:varchar(36);not null;index" json:"user_id"`
+	User         User       `gorm:"foreignKey:UserID" json:"-"`
+	UserAgent    string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IPAddress    string     `gorm:"type:varchar(45)" json:"ip_address"`
+	LastUsedAt   time.Time  `gorm:"not null" json:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `gorm:"type:varchar(100)" json:"revoke_reason,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (f *RefreshTokenFamily) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = utils.GenerateUUID()
+	}
+	if f.LastUsedAt.IsZero() {
+		f.LastUsedAt = time.Now()
+	}
+	return nil
+}
+
+func (f *RefreshTokenFamily) IsRevoked() bool {
+	return f.RevokedAt != nil
+}
+
+func (f *RefreshTokenFamily) Revoke(reason string) {
+	now := time.Now()
+	f.RevokedAt = &now
+	f.RevokeReason = reason
+}
+
+// RefreshToken is a single issued refresh token. The ID doubles as the token's
+// jti claim.
+type RefreshToken struct {
+	ID        string             `gorm:"type:varchar(36);primary_key" json:"id"`
+	FamilyID  string             `gorm:"type:varchar(36);not null;index" json:"family_id"`
+	Family    RefreshTokenFamily `gorm:"foreignKey:FamilyID" json:"-"`
+	UserID    string             `gorm:"type:varchar(36);not null;index" json:"user_id"`
+	ExpiresAt time.Time          `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time         `json:"used_at,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+func (t *RefreshToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
+
+func (t *RefreshToken) MarkAsUsed() {
+	now := time.Now()
+	t.UsedAt = &now
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"smart_attendance_backend/utils"
+
+	"gorm.io/gorm"
+)
+
+type Campus struct {
+	ID        string         `gorm:"type:varchar(36);primary_key" json:"id"`
+	Name      string         `gorm:"type:varchar(255);not null" json:"name"`
+	Code      string         `gorm:"type:varchar(50);uniqueIndex;not null" json:"code"`
+	Address   *string        `gorm:"type:text" json:"address,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (c *Campus) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+type Building struct {
+	ID        string         `gorm:"type:varchar(36);primary_key" json:"id"`
+	CampusID  string         `gorm:"type:varchar(36);not null;index" json:"campus_id"`
+	Campus    *Campus        `gorm:"foreignKey:CampusID" json:"campus,omitempty"`
+	Name      string         `gorm:"type:varchar(255);not null" json:"name"`
+	Code      string         `gorm:"type:varchar(50);not null" json:"code"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (b *Building) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+// Room is a classroom or lecture hall. Sessions held in a room inherit its
+// location, geofence and Wi-Fi access points.
+type Room struct {
+	ID              string            `gorm:"type:varchar(36);primary_key" json:"id"`
+	BuildingID      string            `gorm:"type:varchar(36);not null;index" json:"building_id"`
+	Building        *Building         `gorm:"foreignKey:BuildingID" json:"building,omitempty"`
+	Name            string            `gorm:"type:varchar(255);not null" json:"name"`
+	Code            string            `gorm:"type:varchar(50);not null" json:"code"`
+	Floor           *int              `json:"floor,omitempty"`
+	Capacity        *int              `json:"capacity,omitempty"`
+	LocationLat     float64           `gorm:"type:decimal(10,8);not null" json:"location_lat"`
+	LocationLong    float64           `gorm:"type:decimal(11,8);not null" json:"location_long"`
+	GeofenceRadius  *float64          `gorm:"type:decimal(8,2)" json:"geofence_radius,omitempty"`
+	GeofencePolygon json.RawMessage   `gorm:"type:json" json:"geofence_polygon,omitempty"`
+	AccessPoints    []RoomAccessPoint `gorm:"foreignKey:RoomID" json:"access_points,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt    `gorm:"index" json:"-"`
+}
+
+func (r *Room) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+func (r *Room) SetGeofencePolygon(points []GeoPoint) error {
+	if len(points) == 0 {
+		r.GeofencePolygon = nil
+		return nil
+	}
+	data, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+	r.GeofencePolygon = data
+	return nil
+}
+
+func (r *Room) GetGeofencePolygon() ([]GeoPoint, error) {
+	if len(r.GeofencePolygon) == 0 {
+		return nil, nil
+	}
+	var points []GeoPoint
+	if err := json.Unmarshal(r.GeofencePolygon, &points); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// HasBSSID reports whether one of the room's access points has the given
+// normalized BSSID.
+func (r *Room) HasBSSID(bssid string) bool {
+	for _, ap := range r.AccessPoints {
+		if ap.BSSID == bssid {
+			return true
+		}
+	}
+	return false
+}
+
+// RoomAccessPoint is a Wi-Fi access point installed in a room. A BSSID can
+// only belong to one room.
+type RoomAccessPoint struct {
+	ID        string    `gorm:"type:varchar(36);primary_key" json:"id"`
+	RoomID    string    `gorm:"type:varchar(36);not null;index" json:"room_id"`
+	SSID      string    `gorm:"type:varchar(100);not null" json:"ssid"`
+	BSSID     string    `gorm:"type:varchar(17);uniqueIndex;not null" json:"bssid"`
+	Label     *string   `gorm:"type:varchar(100)" json:"label,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (a *RoomAccessPoint) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = utils.GenerateUUID()
+	}
+	return nil
+}
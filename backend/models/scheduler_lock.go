@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// SchedulerLock is a lease on a background job. Only the instance holding an
+// unexpired lease runs the job, so several replicas can share one database.
+type SchedulerLock struct {
+	Name      string    `gorm:"type:varchar(100);primary_key" json:"name"`
+	Holder    string    `gorm:"type:varchar(100);not null" json:"holder"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
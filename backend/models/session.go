@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"smart_attendance_backend/utils"
@@ -11,28 +12,64 @@ import (
 type SessionStatus string
 
 const (
-	SessionStatusActive   SessionStatus = "active"
-	SessionStatusComplete SessionStatus = "completed"
-	SessionStatusCanceled SessionStatus = "cancelled"
+	// SessionStatusScheduled sessions were opened from the timetable and wait
+	// for the teacher to confirm them
+	SessionStatusScheduled SessionStatus = "scheduled"
+	SessionStatusActive    SessionStatus = "active"
+	SessionStatusComplete  SessionStatus = "completed"
+	SessionStatusCanceled  SessionStatus = "cancelled"
 )
 
+// countdownDurations maps the CountdownDuration values a teacher can pick to
+// how long the session stays open
+var countdownDurations = map[string]time.Duration{
+	"30s": 30 * time.Second,
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+}
+
+// ParseCountdown returns the duration for a CountdownDuration value
+func ParseCountdown(value string) (time.Duration, bool) {
+	d, ok := countdownDurations[value]
+	return d, ok
+}
+
+// GeoPoint is a coordinate in decimal degrees
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
 type AttendanceSession struct {
-	ID                string         `gorm:"type:varchar(36);primary_key" json:"id"`
-	TeacherID         string         `gorm:"type:varchar(36);not null" json:"teacher_id"`
-	Teacher           User           `gorm:"foreignKey:TeacherID" json:"teacher"`
-	SubjectID         string         `gorm:"type:varchar(36);not null" json:"subject_id"`
-	AcademicYear      string         `gorm:"type:varchar(50);not null" json:"academic_year"`
-	StartTime         time.Time      `gorm:"not null" json:"start_time"`
-	EndTime           time.Time      `gorm:"not null" json:"end_time"`
-	CountdownDuration string         `gorm:"type:enum('30s','1m','3m');not null" json:"countdown_duration"`
-	Status            SessionStatus  `gorm:"type:enum('active','completed','cancelled');default:'active'" json:"status"`
-	WifiSSID          string         `gorm:"type:varchar(100);not null" json:"wifi_ssid"`
-	WifiBSSID         string         `gorm:"type:varchar(100);not null" json:"wifi_bssid"`
-	LocationLat       float64        `gorm:"type:decimal(10,8);not null" json:"location_lat"`
-	LocationLong      float64        `gorm:"type:decimal(11,8);not null" json:"location_long"`
-	CreatedAt         time.Time      `json:"created_at"`
-	UpdatedAt         time.Time      `json:"updated_at"`
-	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                string          `gorm:"type:varchar(36);primary_key" json:"id"`
+	TeacherID         string          `gorm:"type:varchar(36);not null" json:"teacher_id"`
+	Teacher           User            `gorm:"foreignKey:TeacherID" json:"teacher"`
+	SubjectID         string          `gorm:"type:varchar(36);not null" json:"subject_id"`
+	AcademicYear      string          `gorm:"type:varchar(50);not null" json:"academic_year"`
+	SectionID         *string         `gorm:"type:varchar(36);index" json:"section_id,omitempty"`
+	RoomID            *string         `gorm:"type:varchar(36);index" json:"room_id,omitempty"`
+	Room              *Room           `gorm:"foreignKey:RoomID" json:"room,omitempty"`
+	StartTime         time.Time       `gorm:"not null" json:"start_time"`
+	EndTime           time.Time       `gorm:"not null" json:"end_time"`
+	CountdownDuration string          `gorm:"type:enum('30s','1m','3m');not null" json:"countdown_duration"`
+	Status            SessionStatus   `gorm:"type:enum('scheduled','active','completed','cancelled');default:'active'" json:"status"`
+	WifiSSID          string          `gorm:"type:varchar(100);not null" json:"wifi_ssid"`
+	WifiBSSID         string          `gorm:"type:varchar(100);not null" json:"wifi_bssid"`
+	LocationLat       float64         `gorm:"type:decimal(10,8);not null" json:"location_lat"`
+	LocationLong      float64         `gorm:"type:decimal(11,8);not null" json:"location_long"`
+	GeofenceRadius    *float64        `gorm:"type:decimal(8,2)" json:"geofence_radius,omitempty"`
+	GeofencePolygon   json.RawMessage `gorm:"type:json" json:"geofence_polygon,omitempty"`
+	// QRRequired sessions also need a fresh token from the teacher's screen,
+	// signed with QRSecret
+	QRRequired bool   `gorm:"default:false;not null" json:"qr_required"`
+	QRSecret   string `gorm:"type:varchar(64)" json:"-"`
+	// TimetableEntryID and ScheduledFor identify the timetable class a
+	// scheduled session was opened for
+	TimetableEntryID *string        `gorm:"type:varchar(36);uniqueIndex:idx_sessions_timetable_class" json:"timetable_entry_id,omitempty"`
+	ScheduledFor     *time.Time     `gorm:"uniqueIndex:idx_sessions_timetable_class" json:"scheduled_for,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (s *AttendanceSession) BeforeCreate(tx *gorm.DB) error {
@@ -46,6 +83,10 @@ func (s *AttendanceSession) IsActive() bool {
 	return s.Status == SessionStatusActive
 }
 
+func (s *AttendanceSession) IsScheduled() bool {
+	return s.Status == SessionStatusScheduled
+}
+
 func (s *AttendanceSession) Complete() {
 	s.Status = SessionStatusComplete
 }
@@ -53,3 +94,61 @@ func (s *AttendanceSession) Complete() {
 func (s *AttendanceSession) Cancel() {
 	s.Status = SessionStatusCanceled
 }
+
+// IsExpired reports whether the countdown has run out
+func (s *AttendanceSession) IsExpired() bool {
+	return !time.Now().Before(s.EndTime)
+}
+
+// IsOpen reports whether students can still mark attendance
+func (s *AttendanceSession) IsOpen() bool {
+	return s.IsActive() && !s.IsExpired()
+}
+
+// RemainingTime returns how long the session stays open, or zero once closed
+func (s *AttendanceSession) RemainingTime() time.Duration {
+	if !s.IsOpen() {
+		return 0
+	}
+	return time.Until(s.EndTime)
+}
+
+func (s *AttendanceSession) SetGeofencePolygon(points []GeoPoint) error {
+	if len(points) == 0 {
+		s.GeofencePolygon = nil
+		return nil
+	}
+	data, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+	s.GeofencePolygon = data
+	return nil
+}
+
+func (s *AttendanceSession) GetGeofencePolygon() ([]GeoPoint, error) {
+	if len(s.GeofencePolygon) == 0 {
+		return nil, nil
+	}
+	var points []GeoPoint
+	if err := json.Unmarshal(s.GeofencePolygon, &points); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// ApplyRoom copies a room's location and geofence onto a new session. The
+// session keeps the Wi-Fi network it was given, falling back to the room's
+// first access point for display; any of the room's access points are
+// accepted when marking.
+func (s *AttendanceSession) ApplyRoom(room *Room) {
+	s.RoomID = &room.ID
+	s.LocationLat = room.LocationLat
+	s.LocationLong = room.LocationLong
+	s.GeofenceRadius = room.GeofenceRadius
+	s.GeofencePolygon = room.GeofencePolygon
+	if s.WifiBSSID == "" && len(room.AccessPoints) > 0 {
+		s.WifiSSID = room.AccessPoints[0].SSID
+		s.WifiBSSID = room.AccessPoints[0].BSSID
+	}
+}
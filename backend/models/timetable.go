@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"smart_attendance_backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// Term is a teaching period timetable entries run in, e.g. "Autumn 2025".
+type Term struct {
+	ID        string         `gorm:"type:varchar(36);primary_key" json:"id"`
+	Name      string         `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	StartsOn  time.Time      `gorm:"type:date;not null" json:"starts_on"`
+	EndsOn    time.Time      `gorm:"type:date;not null" json:"ends_on"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (t *Term) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = utils.GenerateUUID()
+	}
+	return nil
+}
+
+// TimetableEntry is a recurring class: a subject taught by a teacher on one
+// weekday every RepeatEveryWeeks weeks of a term. StartsAt and EndsAt are
+// "HH:MM" in the server's local time zone.
+type TimetableEntry struct {
+	ID           string   `gorm:"type:varchar(36);primary_key" json:"id"`
+	TeacherID    string   `gorm:"type:varchar(36);not null;index" json:"teacher_id"`
+	Teacher      *User    `gorm:"foreignKey:TeacherID" json:"teacher,omitempty"`
+	SubjectID    string   `gorm:"type:varchar(36);not null" json:"subject_id"`
+	Subject      *Subject `gorm:"foreignKey:SubjectID" json:"subject,omitempty"`
+	SectionID    *string  `gorm:"type:varchar(36);index" json:"section_id,omitempty"`
+	Section      *Section `gorm:"foreignKey:SectionID" json:"section,omitempty"`
+	AcademicYear string   `gorm:"type:varchar(50);not null" json:"academic_year"`
+	RoomID       *string  `gorm:"type:varchar(36);index" json:"room_id,omitempty"`
+	Room         *Room    `gorm:"foreignKey:RoomID" json:"room,omitempty"`
+	TermID       string   `gorm:"type:varchar(36);not null;index" json:"term_id"`
+	Term         *Term    `gorm:"foreignKey:TermID" json:"term,omitempty"`
+	// Weekday is 0 for Sunday through 6 for Saturday
+	Weekday  int    `gorm:"not null" json:"weekday"`
+	StartsAt string `gorm:"type:varchar(5);not null" json:"starts_at"`
+	EndsAt   string `gorm:"type:varchar(5);not null" json:"ends_at"`
+	// RepeatEveryWeeks is 1 for weekly classes, 2 for fortnightly and so on,
+	// counted from the first class on or after ValidFrom or the term start
+	RepeatEveryWeeks int        `gorm:"default:1;not null" json:"repeat_every_weeks"`
+	ValidFrom        *time.Time `gorm:"type:date" json:"valid_from,omitempty"`
+	ValidUntil       *time.Time `gorm:"type:date" json:"valid_until,omitempty"`
+	// AutoOpen creates a scheduled session before each class for the teacher
+	// to confirm
+	AutoOpen          bool                 `gorm:"default:false;not null" json:"auto_open"`
+	CountdownDuration string               `gorm:"type:enum('30s','1m','3m');default:'3m';not null" json:"countdown_duration"`
+	RequireQR         bool                 `gorm:"default:false;not null" json:"require_qr"`
+	Exceptions        []TimetableException `gorm:"foreignKey:EntryID" json:"exceptions,omitempty"`
+	CreatedAt         time.Time            `json:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt       `gorm:"index" json:"-"`
+}
+
+func (e *TimetableEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = utils.GenerateUUID()
+	}
+	if e.RepeatEveryWeeks < 1 {
+		e.RepeatEveryWeeks = 1
+	}
+	return nil
+}
+
+// TimetableException skips one date of a timetable entry, e.g. a holiday or
+// a cancelled class.
+type TimetableException struct {
+	ID        string    `gorm:"type:varchar(36);primary_key" json:"id"`
+	EntryID   string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_timetable_exceptions_entry_date" json:"entry_id"`
+	Date      time.Time `gorm:"type:date;not null;uniqueIndex:idx_timetable_exceptions_entry_date" json:"date"`
+	Reason    *string   `gorm:"type:varchar(255)" json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (e *TimetableException) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = utils.GenerateUUID()
+	}
+	return nil
+}
@@ -15,6 +15,15 @@ const (
 	RoleStudent UserRole = "student"
 )
 
+type UserStatus string
+
+const (
+	UserStatusPendingVerification UserStatus = "pending_verification"
+	UserStatusActive              UserStatus = "active"
+	UserStatusSuspended           UserStatus = "suspended"
+	UserStatusDeactivated         UserStatus = "deactivated"
+)
+
 type User struct {
 	ID            string         `gorm:"type:varchar(36);primary_key" json:"id"`
 	Role          UserRole       `gorm:"type:enum('teacher','student');not null" json:"role"`
@@ -28,6 +37,12 @@ type User struct {
 	HighestDegree *string        `gorm:"type:varchar(100)" json:"highest_degree,omitempty"`
 	Experience    *string        `gorm:"type:varchar(50)" json:"experience,omitempty"`
 	PasswordHash  string         `gorm:"type:varchar(255);not null" json:"-"`
+	Verified      bool           `gorm:"default:false" json:"verified"`
+	Status        UserStatus     `gorm:"type:enum('pending_verification','active','suspended','deactivated');default:'pending_verification';not null" json:"status"`
+	TokenVersion  int            `gorm:"default:0;not null" json:"-"`
+	FailedLogins  int            `gorm:"default:0;not null" json:"-"`
+	LockoutCount  int            `gorm:"default:0;not null" json:"-"`
+	LockedUntil   *time.Time     `json:"-"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
@@ -37,6 +52,9 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == "" {
 		u.ID = utils.GenerateUUID()
 	}
+	if u.Status == "" {
+		u.Status = UserStatusPendingVerification
+	}
 	return nil
 }
 
@@ -47,3 +65,87 @@ func (u *User) IsTeacher() bool {
 func (u *User) IsStudent() bool {
 	return u.Role == RoleStudent
 }
+
+// MarkAsVerified records a confirmed OTP and activates accounts that were
+// waiting on it. Suspended or deactivated accounts keep their status.
+func (u *User) MarkAsVerified() {
+	u.Verified = true
+	if u.Status == UserStatusPendingVerification {
+		u.Status = UserStatusActive
+	}
+}
+
+func (u *User) IsActive() bool {
+	return u.Status == UserStatusActive
+}
+
+func (u *User) IsPendingVerification() bool {
+	return u.Status == UserStatusPendingVerification
+}
+
+func (u *User) IsSuspended() bool {
+	return u.Status == UserStatusSuspended
+}
+
+func (u *User) IsDeactivated() bool {
+	return u.Status == UserStatusDeactivated
+}
+
+func (u *User) Suspend() {
+	u.Status = UserStatusSuspended
+}
+
+func (u *User) Deactivate() {
+	u.Status = UserStatusDeactivated
+}
+
+// Reactivate restores a suspended or deactivated account. Accounts that never
+// completed verification go back to pending verification.
+func (u *User) Reactivate() {
+	if u.Verified {
+		u.Status = UserStatusActive
+	} else {
+		u.Status = UserStatusPendingVerification
+	}
+}
+
+// IsLocked reports whether the account is locked out after failed sign-ins
+func (u *User) IsLocked() bool {
+	return u.LockedUntil != nil && time.Now().Before(*u.LockedUntil)
+}
+
+// RegisterFailedLogin counts a failed sign-in and locks the account once
+// maxFailures is reached. Each lockout lasts twice as long as the previous
+// one, capped at maxLockout. It returns true when this failure locked the account.
+func (u *User) RegisterFailedLogin(maxFailures int, lockout, maxLockout time.Duration) bool {
+	u.FailedLogins++
+	if maxFailures <= 0 || u.FailedLogins < maxFailures {
+		return false
+	}
+
+	lockedUntil := time.Now().Add(LockoutDuration(u.LockoutCount, lockout, maxLockout))
+	u.LockedUntil = &lockedUntil
+	u.LockoutCount++
+	u.FailedLogins = 0
+	return true
+}
+
+// LockoutDuration returns how long the lockout after previousLockouts earlier
+// ones lasts: lockout, doubled for each earlier lockout, capped at maxLockout.
+func LockoutDuration(previousLockouts int, lockout, maxLockout time.Duration) time.Duration {
+	duration := lockout
+	for i := 0; i < previousLockouts && duration < maxLockout; i++ {
+		duration *= 2
+	}
+	if duration > maxLockout {
+		duration = maxLockout
+	}
+	return duration
+}
+
+// ResetFailedLogins clears the failure counters after a successful sign-in
+func (u *User) ResetFailedLogins() {
+	u.FailedLogins = 0
+	u.LockoutCount = 0
+	u.LockedUntil = nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegisterFailedLogin(t *testing.T) {
+	const (
+		lockout    = time.Minute
+		maxLockout = 10 * time.Minute
+	)
+
+	tests := []struct {
+		name         string
+		maxFailures  int
+		failures     int
+		lockoutCount int
+		wantLocked   bool
+		wantDuration time.Duration
+		wantFailures int
+		wantLockouts int
+	}{
+		{"below the threshold", 3, 2, 0, false, 0, 2, 0},
+		{"threshold reached", 3, 3, 0, true, time.Minute, 0, 1},
+		{"second lockout doubles", 3, 3, 1, true, 2 * time.Minute, 0, 2},
+		{"fourth lockout", 3, 3, 3, true, 8 * time.Minute, 0, 4},
+		{"capped at the maximum", 3, 3, 4, true, maxLockout, 0, 5},
+		{"stays capped", 3, 3, 40, true, maxLockout, 0, 41},
+		{"failures after a lockout start over", 3, 4, 0, false, time.Minute, 1, 1},
+		{"lockout disabled", 0, 10, 0, false, 0, 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := User{LockoutCount: tt.lockoutCount}
+			start := time.Now()
+			locked := false
+			for i := 0; i < tt.failures; i++ {
+				locked = user.RegisterFailedLogin(tt.maxFailures, lockout, maxLockout)
+			}
+			if locked != tt.wantLocked {
+				t.Errorf("last failure locked = %v, want %v", locked, tt.wantLocked)
+			}
+			if user.FailedLogins != tt.wantFailures || user.LockoutCount != tt.wantLockouts {
+				t.Errorf("FailedLogins, LockoutCount = %d, %d, want %d, %d", user.FailedLogins, user.LockoutCount, tt.wantFailures, tt.wantLockouts)
+			}
+			if tt.wantDuration == 0 {
+				if user.LockedUntil != nil {
+					t.Errorf("LockedUntil = %v, want nil", user.LockedUntil)
+				}
+				return
+			}
+			if user.LockedUntil == nil || !user.IsLocked() {
+				t.Fatalf("account is not locked")
+			}
+			if got := user.LockedUntil.Sub(start); got < tt.wantDuration || got > tt.wantDuration+time.Second {
+				t.Errorf("locked for %s, want %s", got, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestResetFailedLogins(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Hour)
+	user := User{FailedLogins: 2, LockoutCount: 3, LockedUntil: &lockedUntil}
+	user.ResetFailedLogins()
+	if user.FailedLogins != 0 || user.LockoutCount != 0 || user.LockedUntil != nil || user.IsLocked() {
+		t.Errorf("after reset: %d failures, %d lockouts, locked until %v", user.FailedLogins, user.LockoutCount, user.LockedUntil)
+	}
+}
+
+func TestIsLocked(t *testing.T) {
+	past, future := time.Now().Add(-time.Second), time.Now().Add(time.Minute)
+	tests := []struct {
+		name        string
+		lockedUntil *time.Time
+		want        bool
+	}{
+		{"never locked", nil, false},
+		{"lockout running", &future, true},
+		{"lockout over", &past, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := User{LockedUntil: tt.lockedUntil}
+			if got := user.IsLocked(); got != tt.want {
+				t.Errorf("IsLocked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
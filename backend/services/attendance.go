@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+var (
+	ErrAlreadyMarked        = errors.New("attendance already marked for this session")
+	ErrAlreadyMarkedSubject = errors.New("attendance already marked for this subject today")
+	ErrSessionNotHeld       = errors.New("session has not been held")
+	ErrStudentNotEligible   = errors.New("student is not expected to attend this session")
+)
+
+// LateThreshold returns how long after the class starts a mark counts as
+// late: the subject's own threshold, or the configured default. Zero means
+// marks are never late.
+func LateThreshold(tx *gorm.DB, session *models.AttendanceSession) (time.Duration, error) {
+	var subject models.Subject
+	if err := tx.Select("id", "late_after_seconds").Where("id = ?", session.SubjectID).First(&subject).Error; err != nil {
+		return 0, err
+	}
+	if subject.LateAfterSeconds != nil {
+		return time.Duration(*subject.LateAfterSeconds) * time.Second, nil
+	}
+	return config.AppConfig.Attendance.LateAfter, nil
+}
+
+// MarkStatus returns the status of a verified mark made at the given time:
+// late once the late threshold has passed, otherwise present. Timetabled
+// classes are timed from their scheduled start rather than from when the
+// teacher confirmed the session.
+func MarkStatus(tx *gorm.DB, session *models.AttendanceSession, at time.Time) (models.AttendanceStatus, error) {
+	threshold, err := LateThreshold(tx, session)
+	if err != nil {
+		return "", err
+	}
+	classStart := session.StartTime
+	if session.ScheduledFor != nil {
+		classStart = *session.ScheduledFor
+	}
+	if threshold > 0 && at.Sub(classStart) > threshold {
+		return models.AttendanceStatusLate, nil
+	}
+	return models.AttendanceStatusPresent, nil
+}
+
+// FindExistingMark returns the record that stops the student marking the
+// session: their record in the session itself (ErrAlreadyMarked), or an
+// attended record in another session of the same subject on the same day
+// (ErrAlreadyMarkedSubject). It returns nil, nil when the student may mark.
+func FindExistingMark(tx *gorm.DB, studentID string, session *models.AttendanceSession, at time.Time) (*models.AttendanceRecord, error) {
+	var existing models.AttendanceRecord
+	err := tx.Where("session_id = ? AND student_id = ?", session.ID, studentID).First(&existing).Error
+	if err == nil {
+		return &existing, ErrAlreadyMarked
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	dayStart := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	err = tx.Joins("JOIN attendance_sessions ON attendance_sessions.id = attendance_records.session_id").
+		Where("attendance_records.student_id = ? AND attendance_records.status IN ?", studentID, models.AttendedStatuses).
+		Where("attendance_sessions.subject_id = ?", session.SubjectID).
+		Where("attendance_records.marked_at >= ? AND attendance_records.marked_at < ?", dayStart, dayStart.AddDate(0, 0, 1)).
+		First(&existing).Error
+	if err == nil {
+		return &existing, ErrAlreadyMarkedSubject
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// CreateAttendanceRecord stores a verified mark. The student's row is locked
+// so concurrent taps are serialised, and the session row is share-locked so a
+// mark cannot race the session being closed. If the student has already
+// marked, the original record is returned with ErrAlreadyMarked or
+// ErrAlreadyMarkedSubject, including when a concurrent insert wins on the
+// (session, student) unique index. The QR step and challenge the attempt was
+// verified with are spent in the same transaction.
+func CreateAttendanceRecord(record *models.AttendanceRecord, attempt *MarkAttempt) (*models.AttendanceRecord, error) {
+	var existing *models.AttendanceRecord
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		var student models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", record.StudentID).First(&student).Error; err != nil {
+			return err
+		}
+
+		var session models.AttendanceSession
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("id = ?", record.SessionID).First(&session).Error; err != nil {
+			return err
+		}
+		if !session.IsActive() {
+			return ErrSessionNotActive
+		}
+
+		var err error
+		existing, err = FindExistingMark(tx, record.StudentID, &session, record.MarkedAt)
+		if err != nil {
+			return err
+		}
+		if err := attempt.ConsumeTokens(tx); err != nil {
+			return err
+		}
+		return tx.Omit(clause.Associations).Create(record).Error
+	})
+
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		var original models.AttendanceRecord
+		if lookupErr := models.GetDB().Where("session_id = ? AND student_id = ?", record.SessionID, record.StudentID).
+			First(&original).Error; lookupErr != nil {
+			return nil, err
+		}
+		return &original, ErrAlreadyMarked
+	}
+	if err != nil {
+		return existing, err
+	}
+	return record, nil
+}
+
+// SetAttendanceStatus records a teacher's decision about a student in an
+// active or completed session, creating the record if the student has none.
+// A teacher marking a student present is stored as manually marked unless
+// the student's own verified mark already says so. The boolean reports
+// whether the record was created.
+func SetAttendanceStatus(sessionID, studentID string, status models.AttendanceStatus, note *string, teacherID string) (*models.AttendanceRecord, bool, error) {
+	var record models.AttendanceRecord
+	created := false
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		var session models.AttendanceSession
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("id = ?", sessionID).First(&session).Error; err != nil {
+			return err
+		}
+		if !session.IsActive() && session.Status != models.SessionStatusComplete {
+			return ErrSessionNotHeld
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("session_id = ? AND student_id = ?", sessionID, studentID).First(&record).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		created = err != nil
+		if created {
+			eligible, err := IsStudentEligible(tx, studentID, &session)
+			if err != nil {
+				return err
+			}
+			if !eligible {
+				return ErrStudentNotEligible
+			}
+			record = models.AttendanceRecord{
+				SessionID:          sessionID,
+				StudentID:          studentID,
+				MarkedAt:           time.Now(),
+				VerificationMethod: models.VerificationMethodNone,
+			}
+		}
+
+		if status == models.AttendanceStatusPresent &&
+			(record.VerificationMethod == models.VerificationMethodNone || !record.Status.Attended()) {
+			status = models.AttendanceStatusManual
+		}
+		record.Status = status
+		record.Note = note
+		record.MarkedByUserID = &teacherID
+		return tx.Omit(clause.Associations).Save(&record).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &record, created, nil
+}
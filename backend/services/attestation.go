@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+var (
+	ErrInvalidPublicKey  = errors.New("device public key must be an Ed25519 or ECDSA P-256 key")
+	ErrChallengeNotFound = errors.New("attendance challenge not found")
+	ErrChallengeExpired  = errors.New("attendance challenge has expired")
+	ErrChallengeUsed     = errors.New("attendance challenge has already been used")
+	ErrInvalidSignature  = errors.New("attendance signature is invalid")
+)
+
+// ParseDevicePublicKey accepts a device public key as a PEM or base64 encoded
+// SubjectPublicKeyInfo, or a base64 encoded raw 32-byte Ed25519 key. Only
+// Ed25519 and ECDSA P-256 keys are accepted, as both are available in the
+// Android and iOS keystores.
+func ParseDevicePublicKey(encoded string) (crypto.PublicKey, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	var der []byte
+	if block, _ := pem.Decode([]byte(encoded)); block != nil {
+		der = block.Bytes
+	} else {
+		raw, err := decodeBase64(encoded)
+		if err != nil {
+			return nil, ErrInvalidPublicKey
+		}
+		if len(raw) == ed25519.PublicKeySize {
+			return ed25519.PublicKey(raw), nil
+		}
+		der = raw
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return key, nil
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P256() {
+			return key, nil
+		}
+	}
+	return nil, ErrInvalidPublicKey
+}
+
+// IssueChallenge creates a nonce the student's bound device must sign to mark
+// attendance in the session.
+func IssueChallenge(user *models.User, session *models.AttendanceSession, device *models.UserDevice) (*models.AttendanceChallenge, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	challenge := models.AttendanceChallenge{
+		SessionID: session.ID,
+		UserID:    user.ID,
+		DeviceID:  device.DeviceID,
+		Nonce:     hex.EncodeToString(nonce),
+		ExpiresAt: time.Now().Add(config.AppConfig.Attendance.ChallengeTTL),
+	}
+	if session.EndTime.Before(challenge.ExpiresAt) {
+		challenge.ExpiresAt = session.EndTime
+	}
+	if err := models.GetDB().Create(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// AttestationPayload is the string a device signs to mark attendance:
+// nonce|session_id|latitude|longitude|bssid. Coordinates use six decimal
+// places and the BSSID is lowercase with colons; absent values are empty.
+func AttestationPayload(nonce, sessionID string, latitude, longitude *float64, bssid *string) string {
+	formatCoord := func(value *float64) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*value, 'f', 6, 64)
+	}
+	normalized := ""
+	if bssid != nil {
+		normalized = NormalizeBSSID(*bssid)
+	}
+	return strings.Join([]string{nonce, sessionID, formatCoord(latitude), formatCoord(longitude), normalized}, "|")
+}
+
+// VerifyDeviceSignature checks a base64 signature over payload. Ed25519
+// signatures are over the payload itself; ECDSA signatures are over its
+// SHA-256 digest, in ASN.1 DER or raw r||s form.
+func VerifyDeviceSignature(publicKey crypto.PublicKey, payload, signature string) error {
+	sig, err := decodeBase64(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, []byte(payload), sig) {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256([]byte(payload))
+		if ecdsa.VerifyASN1(key, digest[:], sig) {
+			return nil
+		}
+		if len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			if ecdsa.Verify(key, digest[:], r, s) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// FindChallenge looks up the unused, unexpired nonce issued to the user's
+// device for the session.
+func FindChallenge(nonce, sessionID, userID, deviceID string) (*models.AttendanceChallenge, error) {
+	db := models.GetDB()
+	var challenge models.AttendanceChallenge
+	err := db.Where("nonce = ? AND session_id = ? AND user_id = ? AND device_id = ?", nonce, sessionID, userID, deviceID).
+		First(&challenge).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if challenge.IsUsed() {
+		return nil, ErrChallengeUsed
+	}
+	if challenge.IsExpired() {
+		return nil, ErrChallengeExpired
+	}
+	return &challenge, nil
+}
+
+// ConsumeChallenge marks a challenge used. A nonce can only be consumed
+// once; it is called when the mark is stored, so a mark that fails another
+// check leaves the challenge usable until it expires.
+func ConsumeChallenge(tx *gorm.DB, challenge *models.AttendanceChallenge) error {
+	result := tx.Model(challenge).Where("used_at IS NULL").Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrChallengeUsed
+	}
+	return nil
+}
+
+// AttestationVerifier requires the mark to be signed by the bound device's
+// key over a fresh server-issued nonce. It relies on DeviceBindingVerifier
+// running first. When Required is false, marks without a nonce are let
+// through so older app versions keep working.
+type AttestationVerifier struct {
+	Required bool
+}
+
+func (AttestationVerifier) Name() string { return CheckAttestation }
+
+func (v AttestationVerifier) Verify(ctx context.Context, attempt *MarkAttempt) CheckResult {
+	if attempt.Nonce == "" || attempt.Signature == "" {
+		if v.Required {
+			return CheckResult{Status: CheckFailed, Message: "Signed challenge not provided"}
+		}
+		return CheckResult{Status: CheckSkipped, Message: "Signed challenge not provided"}
+	}
+
+	device := attempt.BoundDevice
+	if device == nil {
+		return CheckResult{Status: CheckFailed, Message: "Device is not verified"}
+	}
+	if device.PublicKey == nil {
+		return CheckResult{Status: CheckFailed, Message: "No key is registered for this device"}
+	}
+	publicKey, err := ParseDevicePublicKey(*device.PublicKey)
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Message: "Registered device key is invalid"}
+	}
+
+	payload := AttestationPayload(attempt.Nonce, attempt.Session.ID, attempt.Latitude, attempt.Longitude, attempt.WifiBSSID)
+	if err := VerifyDeviceSignature(publicKey, payload, attempt.Signature); err != nil {
+		return CheckResult{Status: CheckFailed, Message: "Signature does not match this device"}
+	}
+
+	// The nonce is only spent when the mark is stored, so neither a forged
+	// request nor a failed check burns a genuine device's challenge
+	challenge, err := FindChallenge(attempt.Nonce, attempt.Session.ID, attempt.Student.ID, device.DeviceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrChallengeExpired):
+			return CheckResult{Status: CheckFailed, Message: "Challenge has expired. Try again"}
+		case errors.Is(err, ErrChallengeUsed):
+			return CheckResult{Status: CheckFailed, Message: "Challenge has already been used"}
+		default:
+			return CheckResult{Status: CheckFailed, Message: "Challenge not recognised"}
+		}
+	}
+	attempt.Challenge = challenge
+	return CheckResult{Status: CheckPassed}
+}
+
+func decodeBase64(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := encoding.DecodeString(value); err == nil {
+			return data, nil
+		}
+	}
+	return nil, errors.New("invalid base64")
+}
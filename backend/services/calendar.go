@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/models"
+)
+
+// Calendar holds the calendar events of a date range and answers which days
+// in it are teaching days.
+type Calendar struct {
+	events []models.CalendarEvent
+}
+
+// LoadCalendar loads the calendar events overlapping the dates from to to,
+// inclusive. A zero from or to leaves that end of the range open.
+func LoadCalendar(from, to time.Time) (*Calendar, error) {
+	query := models.GetDB().Order("starts_on")
+	if !to.IsZero() {
+		query = query.Where("starts_on <= ?", to.Format(dateLayout))
+	}
+	if !from.IsZero() {
+		query = query.Where("ends_on >= ?", from.Format(dateLayout))
+	}
+
+	var events []models.CalendarEvent
+	if err := query.Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return &Calendar{events: events}, nil
+}
+
+// NonTeachingEvent returns the event that makes day a non-teaching day, or
+// nil if classes run that day.
+func (c *Calendar) NonTeachingEvent(day time.Time) *models.CalendarEvent {
+	for i := range c.events {
+		if c.events[i].Covers(day) {
+			return &c.events[i]
+		}
+	}
+	return nil
+}
+
+// IsTeachingDay reports whether classes run on day.
+func (c *Calendar) IsTeachingDay(day time.Time) bool {
+	return c.NonTeachingEvent(day) == nil
+}
+
+// CalendarImportResult reports what an iCalendar import changed.
+type CalendarImportResult struct {
+	Created      int                  `json:"created"`
+	Updated      int                  `json:"updated"`
+	TermsCreated int                  `json:"terms_created"`
+	TermsUpdated int                  `json:"terms_updated"`
+	Skipped      []CalendarImportSkip `json:"skipped"`
+}
+
+// CalendarImportSkip is an event the import left out, and why.
+type CalendarImportSkip struct {
+	UID     string `json:"uid,omitempty"`
+	Summary string `json:"summary"`
+	Reason  string `json:"reason"`
+}
+
+// ImportCalendar stores parsed iCalendar events. An event's CATEGORIES pick
+// its kind (holiday, exam, closure); events categorised as a term create or
+// update the term of the same name, and the rest get defaultKind. Events
+// already imported are matched by UID and updated. Scheduled sessions on
+// the new non-teaching days are cancelled.
+func ImportCalendar(events []ICalEvent, defaultKind models.CalendarEventKind) (*CalendarImportResult, error) {
+	result := &CalendarImportResult{Skipped: []CalendarImportSkip{}}
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		for _, event := range events {
+			summary := strings.TrimSpace(event.Summary)
+			switch {
+			case event.Recurring:
+				result.Skipped = append(result.Skipped, CalendarImportSkip{UID: event.UID, Summary: summary, Reason: "recurring events are not supported"})
+				continue
+			case summary == "":
+				result.Skipped = append(result.Skipped, CalendarImportSkip{UID: event.UID, Reason: "event has no SUMMARY"})
+				continue
+			}
+
+			kind, isTerm := calendarEventKind(event.Categories, defaultKind)
+			if isTerm {
+				created, err := importTerm(tx, summary, event)
+				if err != nil {
+					return err
+				}
+				if created {
+					result.TermsCreated++
+				} else {
+					result.TermsUpdated++
+				}
+				continue
+			}
+
+			created, err := importCalendarEvent(tx, kind, summary, event)
+			if err != nil {
+				return err
+			}
+			if created {
+				result.Created++
+			} else {
+				result.Updated++
+			}
+			if err := CancelScheduledSessionsBetween(tx, event.StartsOn, event.EndsOn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CancelScheduledSessionsBetween cancels the sessions waiting to be
+// confirmed for classes on the dates from to to, inclusive.
+func CancelScheduledSessionsBetween(tx *gorm.DB, from, to time.Time) error {
+	return tx.Model(&models.AttendanceSession{}).
+		Where("status = ? AND scheduled_for >= ? AND scheduled_for < ?",
+			models.SessionStatusScheduled, localDate(from), localDate(to).AddDate(0, 0, 1)).
+		Update("status", models.SessionStatusCanceled).Error
+}
+
+func importCalendarEvent(tx *gorm.DB, kind models.CalendarEventKind, summary string, event ICalEvent) (bool, error) {
+	var existing models.CalendarEvent
+	query := tx.Where("kind = ? AND title = ? AND starts_on = ?", kind, summary, event.StartsOn.Format(dateLayout))
+	if event.UID != "" {
+		query = tx.Where("uid = ?", event.UID)
+	}
+	err := query.First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	created := err != nil
+
+	existing.Kind = kind
+	existing.Title = summary
+	existing.Description = nil
+	if event.Description != "" {
+		existing.Description = &event.Description
+	}
+	existing.StartsOn = event.StartsOn
+	existing.EndsOn = event.EndsOn
+	if event.UID != "" {
+		existing.UID = &event.UID
+	}
+	return created, tx.Save(&existing).Error
+}
+
+func importTerm(tx *gorm.DB, name string, event ICalEvent) (bool, error) {
+	var term models.Term
+	err := tx.Where("name = ?", name).First(&term).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	term.Name = name
+	term.StartsOn = event.StartsOn
+	term.EndsOn = event.EndsOn
+	return err != nil, tx.Save(&term).Error
+}
+
+// calendarEventKind maps iCalendar CATEGORIES to an event kind, or reports
+// that the event is a term
+func calendarEventKind(categories []string, defaultKind models.CalendarEventKind) (models.CalendarEventKind, bool) {
+	for _, category := range categories {
+		category = strings.ToLower(category)
+		switch {
+		case strings.Contains(category, "term") || strings.Contains(category, "semester"):
+			return "", true
+		case strings.Contains(category, "exam"):
+			return models.CalendarEventExamPeriod, false
+		case strings.Contains(category, "closure") || strings.Contains(category, "closed"):
+			return models.CalendarEventClosure, false
+		case strings.Contains(category, "holiday"):
+			return models.CalendarEventHoliday, false
+		}
+	}
+	return defaultKind, false
+}
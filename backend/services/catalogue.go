@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/models"
+)
+
+var (
+	ErrUnknownCourse       = errors.New("course not found")
+	ErrUnknownAcademicYear = errors.New("academic year not found or no longer open")
+	ErrUnknownSubject      = errors.New("subject not found")
+	ErrUnknownSection      = errors.New("section not found")
+	ErrSubjectNotAssigned  = errors.New("subject is not assigned to this teacher")
+	ErrAcademicYearNeeded  = errors.New("academic year is required without a section")
+	ErrAcademicYearClash   = errors.New("academic year does not match the section")
+)
+
+// ResolveCourse finds a course by code or name.
+func ResolveCourse(value string) (*models.Course, error) {
+	value = strings.TrimSpace(value)
+	var course models.Course
+	err := models.GetDB().Where("code = ? OR name = ?", value, value).First(&course).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownCourse
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &course, nil
+}
+
+// ResolveAcademicYear finds an active academic year by name.
+func ResolveAcademicYear(name string) (*models.AcademicYear, error) {
+	var year models.AcademicYear
+	err := models.GetDB().Where("name = ? AND active = ?", strings.TrimSpace(name), true).First(&year).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownAcademicYear
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &year, nil
+}
+
+// CheckTeachingAssignment verifies the teacher teaches the subject, either to
+// every section or to the given one. It returns the subject and, when
+// sectionID is set, the section with its academic year loaded.
+func CheckTeachingAssignment(teacherID, subjectID string, sectionID *string) (*models.Subject, *models.Section, error) {
+	db := models.GetDB()
+
+	var subject models.Subject
+	if err := db.Where("id = ?", subjectID).First(&subject).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrUnknownSubject
+		}
+		return nil, nil, err
+	}
+
+	var section *models.Section
+	query := db.Model(&models.TeacherSubject{}).Where("teacher_id = ? AND subject_id = ?", teacherID, subject.ID)
+	if sectionID != nil {
+		section = &models.Section{}
+		if err := db.Preload("AcademicYear").Where("id = ?", *sectionID).First(section).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, ErrUnknownSection
+			}
+			return nil, nil, err
+		}
+		query = query.Where("section_id IS NULL OR section_id = ?", section.ID)
+	}
+
+	var assignments int64
+	if err := query.Count(&assignments).Error; err != nil {
+		return nil, nil, err
+	}
+	if assignments == 0 {
+		return nil, nil, ErrSubjectNotAssigned
+	}
+	return &subject, section, nil
+}
+
+// ResolveSessionAudience checks the teacher may teach the subject to the
+// section, or to the whole academic year when sectionID is nil, and returns
+// the academic year the class is for. academicYear may be empty when a
+// section is given.
+func ResolveSessionAudience(teacherID, subjectID string, sectionID *string, academicYear string) (string, error) {
+	_, section, err := CheckTeachingAssignment(teacherID, subjectID, sectionID)
+	if err != nil {
+		return "", err
+	}
+	if section != nil {
+		if academicYear != "" && academicYear != section.AcademicYear.Name {
+			return "", ErrAcademicYearClash
+		}
+		return section.AcademicYear.Name, nil
+	}
+	if academicYear == "" {
+		return "", ErrAcademicYearNeeded
+	}
+	year, err := ResolveAcademicYear(academicYear)
+	if err != nil {
+		return "", err
+	}
+	return year.Name, nil
+}
+
+// StudentSectionIDs returns the sections a student is enrolled in.
+func StudentSectionIDs(studentID string) ([]string, error) {
+	var sectionIDs []string
+	err := models.GetDB().Model(&models.SectionEnrollment{}).Where("student_id = ?", studentID).Pluck("section_id", &sectionIDs).Error
+	return sectionIDs, err
+}
+
+// EligibleStudentIDs returns the active students expected to attend the
+// session: those enrolled in its section, or otherwise those in its academic
+// year and, if the subject belongs to a course, in that course.
+func EligibleStudentIDs(tx *gorm.DB, session *models.AttendanceSession) ([]string, error) {
+	query, err := eligibleStudents(tx, session)
+	if err != nil {
+		return nil, err
+	}
+	var studentIDs []string
+	err = query.Pluck("users.id", &studentIDs).Error
+	return studentIDs, err
+}
+
+// IsStudentEligible reports whether the student is expected to attend the session.
+func IsStudentEligible(tx *gorm.DB, studentID string, session *models.AttendanceSession) (bool, error) {
+	query, err := eligibleStudents(tx, session)
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	err = query.Where("users.id = ?", studentID).Count(&count).Error
+	return count > 0, err
+}
+
+// TaughtStudentIDs is a subquery of the students a teacher teaches: those
+// enrolled in a section the teacher is assigned to, or in any section of the
+// course of a subject assigned to them without a section.
+func TaughtStudentIDs(tx *gorm.DB, teacherID string) *gorm.DB {
+	newDB := func() *gorm.DB { return tx.Session(&gorm.Session{NewDB: true}) }
+	assignedSections := newDB().Model(&models.TeacherSubject{}).Select("section_id").
+		Where("teacher_id = ? AND section_id IS NOT NULL", teacherID)
+	assignedCourses := newDB().Model(&models.TeacherSubject{}).Select("subjects.course_id").
+		Joins("JOIN subjects ON subjects.id = teacher_subjects.subject_id AND subjects.deleted_at IS NULL").
+		Where("teacher_subjects.teacher_id = ? AND teacher_subjects.section_id IS NULL AND subjects.course_id IS NOT NULL", teacherID)
+	sections := newDB().Model(&models.Section{}).Select("sections.id").
+		Where("sections.id IN (?) OR sections.course_id IN (?)", assignedSections, assignedCourses)
+	return newDB().Model(&models.SectionEnrollment{}).Select("student_id").Where("section_id IN (?)", sections)
+}
+
+// StudentSessions narrows a query on attendance_sessions to the sessions the
+// student is expected to attend, as eligibleStudents decides it: sessions of
+// the sections they are enrolled in, and sessions without a section in their
+// academic year unless the subject belongs to another course.
+func StudentSessions(tx *gorm.DB, student *models.User) *gorm.DB {
+	enrolled := tx.Session(&gorm.Session{NewDB: true}).Model(&models.SectionEnrollment{}).
+		Select("section_id").Where("student_id = ?", student.ID)
+	visible := tx.Session(&gorm.Session{NewDB: true}).Where("attendance_sessions.section_id IN (?)", enrolled)
+
+	if student.AcademicYear != nil {
+		course := ""
+		if student.Course != nil {
+			course = *student.Course
+		}
+		otherCourses := tx.Session(&gorm.Session{NewDB: true}).Model(&models.Subject{}).Select("subjects.id").
+			Joins("JOIN courses ON courses.id = subjects.course_id AND courses.deleted_at IS NULL").
+			Where("courses.code <> ?", course)
+		visible = visible.Or(tx.Session(&gorm.Session{NewDB: true}).
+			Where("attendance_sessions.section_id IS NULL AND attendance_sessions.academic_year = ?", *student.AcademicYear).
+			Where("attendance_sessions.subject_id NOT IN (?)", otherCourses))
+	}
+	return tx.Where(visible)
+}
+
+func eligibleStudents(tx *gorm.DB, session *models.AttendanceSession) (*gorm.DB, error) {
+	query := tx.Model(&models.User{}).
+		Where("users.role = ? AND users.status = ?", models.RoleStudent, models.UserStatusActive)
+
+	if session.SectionID != nil {
+		enrolled := tx.Session(&gorm.Session{NewDB: true}).Model(&models.SectionEnrollment{}).
+			Select("student_id").Where("section_id = ?", *session.SectionID)
+		return query.Where("users.id IN (?)", enrolled), nil
+	}
+
+	query = query.Where("users.academic_year = ?", session.AcademicYear)
+
+	var subject models.Subject
+	err := tx.Session(&gorm.Session{NewDB: true}).Preload("Course").Where("id = ?", session.SubjectID).First(&subject).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && subject.Course != nil {
+		query = query.Where("users.course = ?", subject.Course.Code)
+	}
+	return query, nil
+}
+
+// EnrollmentVerifier requires the student to be one of the session's
+// eligible students, see EligibleStudentIDs.
+type EnrollmentVerifier struct{}
+
+func (EnrollmentVerifier) Name() string { return CheckEnrollment }
+
+func (EnrollmentVerifier) Verify(ctx context.Context, attempt *MarkAttempt) CheckResult {
+	eligible, err := IsStudentEligible(models.GetDB(), attempt.Student.ID, attempt.Session)
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Message: "Could not check enrolment"}
+	}
+	if !eligible {
+		return CheckResult{Status: CheckFailed, Message: "You are not enrolled in this class"}
+	}
+	return CheckResult{Status: CheckPassed}
+}
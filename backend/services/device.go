@@ -0,0 +1,345 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+var (
+	ErrDeviceMismatch       = errors.New("account is bound to a different device")
+	ErrDeviceBoundElsewhere = errors.New("device is bound to another account")
+	ErrRebindPending        = errors.New("a device rebind request is already pending")
+	ErrRebindSameDevice     = errors.New("account is already bound to this device")
+	ErrRebindNotFound       = errors.New("device rebind request not found")
+	ErrRebindNotPending     = errors.New("device rebind request has already been reviewed")
+)
+
+// RebindCooldownError is returned when a student asks to move to a new device
+// too soon after the current one was bound.
+type RebindCooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RebindCooldownError) Error() string {
+	return fmt.Sprintf("device was bound recently, retry in %s", e.RetryAfter)
+}
+
+// DeviceDetails identifies a device presented by a client. PublicKey is the
+// device's attestation key, see ParseDevicePublicKey.
+type DeviceDetails struct {
+	DeviceID    string
+	PublicKey   *string
+	DeviceModel *string
+	DeviceBrand *string
+	OSVersion   *string
+}
+
+// validate checks the public key, if one is given, is a supported key type.
+func (d DeviceDetails) validate() error {
+	if d.PublicKey == nil {
+		return nil
+	}
+	_, err := ParseDevicePublicKey(*d.PublicKey)
+	return err
+}
+
+// RebindReviewer identifies the teacher or admin reviewing a rebind request.
+// Exactly one of the fields is set.
+type RebindReviewer struct {
+	UserID  *string
+	AdminID *string
+}
+
+// ActiveDevice returns the user's bound device, or nil if none is bound.
+func ActiveDevice(userID string) (*models.UserDevice, error) {
+	return activeDevice(models.GetDB(), userID)
+}
+
+func activeDevice(tx *gorm.DB, userID string) (*models.UserDevice, error) {
+	var device models.UserDevice
+	err := tx.Where("user_id = ? AND status = ?", userID, models.DeviceStatusActive).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// BindDevice binds the user to the device if they have none yet, and
+// otherwise checks the device is the bound one. It returns the user's bound
+// device along with ErrDeviceMismatch when a different device is presented.
+func BindDevice(user *models.User, details DeviceDetails, ipAddress string) (*models.UserDevice, error) {
+	if err := details.validate(); err != nil {
+		return nil, err
+	}
+
+	var device *models.UserDevice
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		// Lock the user row so two first logins cannot both bind
+		var locked models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", user.ID).First(&locked).Error; err != nil {
+			return err
+		}
+
+		var err error
+		device, err = activeDevice(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if device != nil {
+			if device.DeviceID != details.DeviceID {
+				return ErrDeviceMismatch
+			}
+			device.LastSeenAt = &now
+			if device.PublicKey == nil && details.PublicKey != nil {
+				device.PublicKey = details.PublicKey
+			}
+			return tx.Model(device).Select("last_seen_at", "public_key").Updates(device).Error
+		}
+
+		if err := checkDeviceAvailable(tx, user.ID, details.DeviceID); err != nil {
+			return err
+		}
+
+		device = newUserDevice(user.ID, details)
+		device.LastSeenAt = &now
+		if err := tx.Create(device).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, user.ID, "device_bound", ipAddress, map[string]interface{}{"device_id": details.DeviceID})
+	})
+	if errors.Is(err, ErrDeviceMismatch) {
+		recordAudit(models.GetDB(), user.ID, "device_mismatch", ipAddress, map[string]interface{}{
+			"bound_device_id":     device.DeviceID,
+			"presented_device_id": details.DeviceID,
+		})
+		return device, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// RequestRebind asks for the user's binding to move to a new device. Only one
+// request can be pending, and a new one is refused until the configured
+// cooldown has passed since the current device was bound.
+func RequestRebind(user *models.User, details DeviceDetails, reason, ipAddress string) (*models.DeviceRebindRequest, error) {
+	if err := details.validate(); err != nil {
+		return nil, err
+	}
+
+	var request models.DeviceRebindRequest
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		var locked models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", user.ID).First(&locked).Error; err != nil {
+			return err
+		}
+
+		var pending int64
+		if err := tx.Model(&models.DeviceRebindRequest{}).
+			Where("user_id = ? AND status = ?", user.ID, models.RebindStatusPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrRebindPending
+		}
+
+		current, err := activeDevice(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if current != nil {
+			if current.DeviceID == details.DeviceID {
+				return ErrRebindSameDevice
+			}
+			cooldown := config.AppConfig.Security.DeviceRebindCooldown
+			if wait := time.Until(current.BoundAt.Add(cooldown)); wait > 0 {
+				return &RebindCooldownError{RetryAfter: wait}
+			}
+		}
+
+		if err := checkDeviceAvailable(tx, user.ID, details.DeviceID); err != nil {
+			return err
+		}
+
+		request = models.DeviceRebindRequest{
+			UserID:       user.ID,
+			NewDeviceID:  details.DeviceID,
+			NewPublicKey: details.PublicKey,
+			DeviceModel:  details.DeviceModel,
+			DeviceBrand:  details.DeviceBrand,
+			OSVersion:    details.OSVersion,
+			Reason:       reason,
+		}
+		if current != nil {
+			request.CurrentDeviceID = &current.DeviceID
+		}
+		if err := tx.Omit("User").Create(&request).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, user.ID, "device_rebind_requested", ipAddress, map[string]interface{}{
+			"request_id":    request.ID,
+			"new_device_id": details.DeviceID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// CancelRebind withdraws one of the user's pending rebind requests.
+func CancelRebind(userID, requestID string) error {
+	result := models.GetDB().Model(&models.DeviceRebindRequest{}).
+		Where("id = ? AND user_id = ? AND status = ?", requestID, userID, models.RebindStatusPending).
+		Update("status", models.RebindStatusCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRebindNotFound
+	}
+	return nil
+}
+
+// ReviewRebind approves or rejects a pending rebind request. Approval revokes
+// the student's current device and binds the requested one. A teacher may
+// only review requests from students they teach, see TaughtStudentIDs;
+// other requests are reported as not found.
+func ReviewRebind(requestID string, reviewer RebindReviewer, approve bool, note *string, ipAddress string) (*models.DeviceRebindRequest, error) {
+	var request models.DeviceRebindRequest
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", requestID).First(&request).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRebindNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if reviewer.UserID != nil {
+			var taught int64
+			if err := tx.Model(&models.User{}).
+				Where("id = ? AND id IN (?)", request.UserID, TaughtStudentIDs(tx, *reviewer.UserID)).
+				Count(&taught).Error; err != nil {
+				return err
+			}
+			if taught == 0 {
+				return ErrRebindNotFound
+			}
+		}
+		if !request.IsPending() {
+			return ErrRebindNotPending
+		}
+
+		now := time.Now()
+		request.ReviewedByUserID = reviewer.UserID
+		request.ReviewedByAdminID = reviewer.AdminID
+		request.ReviewNote = note
+		request.ReviewedAt = &now
+		request.Status = models.RebindStatusRejected
+		if approve {
+			request.Status = models.RebindStatusApproved
+			if err := rebindDevice(tx, &request); err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("User").Save(&request).Error; err != nil {
+			return err
+		}
+
+		action := "device_rebind_rejected"
+		if approve {
+			action = "device_rebind_approved"
+		}
+		return recordAudit(tx, request.UserID, action, ipAddress, map[string]interface{}{
+			"request_id":           request.ID,
+			"new_device_id":        request.NewDeviceID,
+			"reviewed_by_user_id":  reviewer.UserID,
+			"reviewed_by_admin_id": reviewer.AdminID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// rebindDevice moves the requesting user's binding to the requested device.
+func rebindDevice(tx *gorm.DB, request *models.DeviceRebindRequest) error {
+	var locked models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", request.UserID).First(&locked).Error; err != nil {
+		return err
+	}
+	if err := checkDeviceAvailable(tx, request.UserID, request.NewDeviceID); err != nil {
+		return err
+	}
+
+	current, err := activeDevice(tx, request.UserID)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		current.Revoke()
+		if err := tx.Model(current).Select("status", "revoked_at").Updates(current).Error; err != nil {
+			return err
+		}
+	}
+
+	device := newUserDevice(request.UserID, DeviceDetails{
+		DeviceID:    request.NewDeviceID,
+		PublicKey:   request.NewPublicKey,
+		DeviceModel: request.DeviceModel,
+		DeviceBrand: request.DeviceBrand,
+		OSVersion:   request.OSVersion,
+	})
+	return tx.Create(device).Error
+}
+
+// checkDeviceAvailable refuses a device that is actively bound to another
+// account, which would let one phone mark attendance for several students.
+func checkDeviceAvailable(tx *gorm.DB, userID, deviceID string) error {
+	var count int64
+	if err := tx.Model(&models.UserDevice{}).
+		Where("device_id = ? AND status = ? AND user_id <> ?", deviceID, models.DeviceStatusActive, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDeviceBoundElsewhere
+	}
+	return nil
+}
+
+func newUserDevice(userID string, details DeviceDetails) *models.UserDevice {
+	return &models.UserDevice{
+		UserID:      userID,
+		DeviceID:    details.DeviceID,
+		PublicKey:   details.PublicKey,
+		DeviceModel: details.DeviceModel,
+		DeviceBrand: details.DeviceBrand,
+		OSVersion:   details.OSVersion,
+		Status:      models.DeviceStatusActive,
+	}
+}
+
+// recordAudit writes a user audit log entry.
+func recordAudit(tx *gorm.DB, userID, action, ipAddress string, details interface{}) error {
+	audit := models.AuditLog{UserID: userID, Action: action, IPAddress: ipAddress}
+	if err := audit.SetDetails(details); err != nil {
+		return err
+	}
+	return tx.Omit("User").Create(&audit).Error
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types published on the in-process event bus
+const (
+	EventSessionScheduled = "session.scheduled"
+	EventSessionStarted   = "session.started"
+	EventSessionClosed    = "session.closed"
+	EventAttendanceMarked = "attendance.marked"
+)
+
+// Event is delivered to every subscriber of its type.
+type Event struct {
+	Type       string      `json:"type"`
+	Payload    interface{} `json:"payload"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// SessionScheduledEvent is the payload of EventSessionScheduled.
+type SessionScheduledEvent struct {
+	SessionID    string    `json:"session_id"`
+	TeacherID    string    `json:"teacher_id"`
+	SubjectID    string    `json:"subject_id"`
+	AcademicYear string    `json:"academic_year"`
+	SectionID    *string   `json:"section_id,omitempty"`
+	RoomID       *string   `json:"room_id,omitempty"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+}
+
+// SessionStartedEvent is the payload of EventSessionStarted.
+type SessionStartedEvent struct {
+	SessionID    string    `json:"session_id"`
+	TeacherID    string    `json:"teacher_id"`
+	TeacherName  string    `json:"teacher_name"`
+	SubjectID    string    `json:"subject_id"`
+	AcademicYear string    `json:"academic_year"`
+	SectionID    *string   `json:"section_id,omitempty"`
+	RoomID       *string   `json:"room_id,omitempty"`
+	QRRequired   bool      `json:"qr_required"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+}
+
+// AttendanceMarkedEvent is the payload of EventAttendanceMarked.
+type AttendanceMarkedEvent struct {
+	SessionID          string    `json:"session_id"`
+	TeacherID          string    `json:"teacher_id"`
+	RecordID           string    `json:"record_id"`
+	StudentID          string    `json:"student_id"`
+	StudentName        string    `json:"student_name"`
+	RollNumber         *string   `json:"roll_number,omitempty"`
+	Status             string    `json:"status"`
+	VerificationMethod string    `json:"verification_method"`
+	MarkedAt           time.Time `json:"marked_at"`
+}
+
+// SessionClosedEvent is the payload of EventSessionClosed.
+type SessionClosedEvent struct {
+	SessionID    string    `json:"session_id"`
+	TeacherID    string    `json:"teacher_id"`
+	SubjectID    string    `json:"subject_id"`
+	AcademicYear string    `json:"academic_year"`
+	SectionID    *string   `json:"section_id,omitempty"`
+	Status       string    `json:"status"`
+	PresentCount int       `json:"present_count"`
+	AbsentCount  int       `json:"absent_count"`
+	ClosedAt     time.Time `json:"closed_at"`
+}
+
+var (
+	eventMu       sync.RWMutex
+	eventHandlers = make(map[string][]func(Event))
+)
+
+// SubscribeEvents registers a handler for an event type. Handlers run on
+// their own goroutine and must not assume delivery order.
+func SubscribeEvents(eventType string, handler func(Event)) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	eventHandlers[eventType] = append(eventHandlers[eventType], handler)
+}
+
+// PublishEvent delivers an event to the subscribers of its type without
+// waiting for them.
+func PublishEvent(eventType string, payload interface{}) {
+	eventMu.RLock()
+	handlers := make([]func(Event), len(eventHandlers[eventType]))
+	copy(handlers, eventHandlers[eventType])
+	eventMu.RUnlock()
+
+	event := Event{Type: eventType, Payload: payload, OccurredAt: time.Now()}
+	for _, handler := range handlers {
+		go func(handler func(Event)) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("event handler for %s panicked: %v", eventType, r)
+				}
+			}()
+			handler(event)
+		}(handler)
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"math"
+
+	"smart_attendance_backend/models"
+)
+
+// earthRadiusMeters is the mean Earth radius used for distance calculations
+const earthRadiusMeters = 6371000.0
+
+// HaversineDistance returns the great-circle distance in meters between two
+// points given in decimal degrees.
+func HaversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusMeters * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Geofence is a circle of RadiusMeters around Center, or a polygon when
+// Polygon has at least three points.
+type Geofence struct {
+	Center       models.GeoPoint
+	RadiusMeters float64
+	Polygon      []models.GeoPoint
+}
+
+// GeofenceMatch describes where a point lies relative to a geofence.
+type GeofenceMatch struct {
+	Inside bool
+	// DistanceMeters is the distance from the geofence centre, which for a
+	// polygon is the average of its vertices
+	DistanceMeters float64
+	// OutsideMeters is how far beyond the boundary the point is, 0 when inside
+	OutsideMeters float64
+}
+
+// IsPolygon reports whether the geofence is a polygon rather than a circle.
+func (g Geofence) IsPolygon() bool {
+	return len(g.Polygon) >= 3
+}
+
+// Evaluate checks a reported position against the geofence. A point up to
+// toleranceMeters beyond the boundary still counts as inside.
+func (g Geofence) Evaluate(point models.GeoPoint, toleranceMeters float64) GeofenceMatch {
+	var match GeofenceMatch
+	if g.IsPolygon() {
+		center := polygonCenter(g.Polygon)
+		match.DistanceMeters = HaversineDistance(center.Lat, center.Lng, point.Lat, point.Lng)
+		match.OutsideMeters = distanceOutsidePolygon(g.Polygon, point)
+	} else {
+		match.DistanceMeters = HaversineDistance(g.Center.Lat, g.Center.Lng, point.Lat, point.Lng)
+		match.OutsideMeters = math.Max(0, match.DistanceMeters-g.RadiusMeters)
+	}
+	match.Inside = match.OutsideMeters <= math.Max(0, toleranceMeters)
+	return match
+}
+
+// polygonCenter returns the average of the polygon's vertices
+func polygonCenter(polygon []models.GeoPoint) models.GeoPoint {
+	var center models.GeoPoint
+	for _, p := range polygon {
+		center.Lat += p.Lat
+		center.Lng += p.Lng
+	}
+	center.Lat /= float64(len(polygon))
+	center.Lng /= float64(len(polygon))
+	return center
+}
+
+// distanceOutsidePolygon returns 0 for points inside the polygon, otherwise
+// the distance to its nearest edge. Classrooms are small enough to project
+// onto a local flat plane around the point.
+func distanceOutsidePolygon(polygon []models.GeoPoint, point models.GeoPoint) float64 {
+	metersPerDegLat := earthRadiusMeters * math.Pi / 180
+	metersPerDegLng := metersPerDegLat * math.Cos(point.Lat*math.Pi/180)
+	project := func(p models.GeoPoint) (float64, float64) {
+		return (p.Lng - point.Lng) * metersPerDegLng, (p.Lat - point.Lat) * metersPerDegLat
+	}
+
+	// The point is the origin of the plane; cast a ray along +x
+	inside := false
+	nearest := math.Inf(1)
+	for i := range polygon {
+		x1, y1 := project(polygon[i])
+		x2, y2 := project(polygon[(i+1)%len(polygon)])
+
+		if (y1 > 0) != (y2 > 0) && x1+(0-y1)*(x2-x1)/(y2-y1) > 0 {
+			inside = !inside
+		}
+		nearest = math.Min(nearest, distanceToSegment(x1, y1, x2, y2))
+	}
+	if inside {
+		return 0
+	}
+	return nearest
+}
+
+// distanceToSegment returns the distance from the origin to the segment
+// between (x1, y1) and (x2, y2).
+func distanceToSegment(x1, y1, x2, y2 float64) float64 {
+	dx, dy := x2-x1, y2-y1
+	lengthSq := dx*dx + dy*dy
+	t := 0.0
+	if lengthSq > 0 {
+		t = math.Max(0, math.Min(1, -(x1*dx+y1*dy)/lengthSq))
+	}
+	return math.Hypot(x1+t*dx, y1+t*dy)
+}
+
+// SessionGeofence returns the geofence a session's marks are checked against.
+// defaultRadius applies when the session does not set its own radius.
+func SessionGeofence(session *models.AttendanceSession, defaultRadius float64) (Geofence, error) {
+	fence := Geofence{
+		Center:       models.GeoPoint{Lat: session.LocationLat, Lng: session.LocationLong},
+		RadiusMeters: defaultRadius,
+	}
+	if session.GeofenceRadius != nil && *session.GeofenceRadius > 0 {
+		fence.RadiusMeters = *session.GeofenceRadius
+	}
+
+	polygon, err := session.GetGeofencePolygon()
+	if err != nil {
+		return fence, err
+	}
+	fence.Polygon = polygon
+	return fence, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"smart_attendance_backend/models"
+)
+
+var testCenter = models.GeoPoint{Lat: 12.9716, Lng: 77.5946}
+
+// offset returns the point north and east meters away from testCenter
+func offset(north, east float64) models.GeoPoint {
+	metersPerDegLat := earthRadiusMeters * math.Pi / 180
+	metersPerDegLng := metersPerDegLat * math.Cos(testCenter.Lat*math.Pi/180)
+	return models.GeoPoint{Lat: testCenter.Lat + north/metersPerDegLat, Lng: testCenter.Lng + east/metersPerDegLng}
+}
+
+func TestGeofenceEvaluateCircle(t *testing.T) {
+	fence := Geofence{Center: testCenter, RadiusMeters: 50}
+
+	tests := []struct {
+		name        string
+		point       models.GeoPoint
+		tolerance   float64
+		wantInside  bool
+		wantOutside float64
+	}{
+		{"centre", testCenter, 0, true, 0},
+		{"just inside", offset(49, 0), 0, true, 0},
+		{"on boundary", offset(50, 0), 0, true, 0},
+		{"just outside", offset(55, 0), 0, false, 5},
+		{"outside within tolerance", offset(55, 0), 10, true, 5},
+		{"outside beyond tolerance", offset(0, -65), 10, false, 15},
+		{"negative tolerance is ignored", offset(51, 0), -5, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := fence.Evaluate(tt.point, tt.tolerance)
+			if match.Inside != tt.wantInside {
+				t.Errorf("Inside = %v, want %v", match.Inside, tt.wantInside)
+			}
+			if math.Abs(match.OutsideMeters-tt.wantOutside) > 0.1 {
+				t.Errorf("OutsideMeters = %.2f, want %.2f", match.OutsideMeters, tt.wantOutside)
+			}
+		})
+	}
+}
+
+func TestGeofenceEvaluatePolygon(t *testing.T) {
+	// An L-shaped room: 40m square with its north-east quarter cut out
+	fence := Geofence{Polygon: []models.GeoPoint{
+		offset(-20, -20),
+		offset(-20, 20),
+		offset(0, 20),
+		offset(0, 0),
+		offset(20, 0),
+		offset(20, -20),
+	}}
+
+	tests := []struct {
+		name        string
+		point       models.GeoPoint
+		tolerance   float64
+		wantInside  bool
+		wantOutside float64
+	}{
+		{"inside south-west corner", offset(-10, -10), 0, true, 0},
+		{"inside north-west arm", offset(10, -10), 0, true, 0},
+		{"inside south-east arm", offset(-10, 10), 0, true, 0},
+		{"in the cut-out notch", offset(10, 10), 0, false, 10},
+		{"east of the room", offset(-10, 25), 0, false, 5},
+		{"east of the room within tolerance", offset(-10, 25), 10, true, 5},
+		{"beyond a vertex", offset(-23, -24), 0, false, 5},
+		{"far away", offset(500, 500), 50, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := fence.Evaluate(tt.point, tt.tolerance)
+			if match.Inside != tt.wantInside {
+				t.Errorf("Inside = %v, want %v", match.Inside, tt.wantInside)
+			}
+			if tt.wantOutside > 0 && math.Abs(match.OutsideMeters-tt.wantOutside) > 0.1 {
+				t.Errorf("OutsideMeters = %.2f, want %.2f", match.OutsideMeters, tt.wantOutside)
+			}
+		})
+	}
+}
+
+func TestGeofenceVerifierAccuracy(t *testing.T) {
+	verifier := GeofenceVerifier{DefaultRadiusMeters: 50, MaxAccuracyMeters: 100, ToleranceMeters: 10}
+	session := &models.AttendanceSession{LocationLat: testCenter.Lat, LocationLong: testCenter.Lng}
+
+	tests := []struct {
+		name     string
+		point    models.GeoPoint
+		accuracy float64
+		want     CheckStatus
+	}{
+		{"precise fix inside", offset(40, 0), 5, CheckPassed},
+		{"precise fix just outside", offset(55, 0), 3, CheckFailed},
+		{"accuracy covers a small overshoot", offset(55, 0), 8, CheckPassed},
+		{"coarse accuracy does not widen the fence", offset(145, 0), 99, CheckFailed},
+		{"accuracy is capped at the tolerance", offset(65, 0), 99, CheckFailed},
+		{"fix too coarse", offset(0, 0), 150, CheckFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, lng, accuracy := tt.point.Lat, tt.point.Lng, tt.accuracy
+			result := verifier.Verify(context.Background(), &MarkAttempt{
+				Session:   session,
+				Latitude:  &lat,
+				Longitude: &lng,
+				Accuracy:  &accuracy,
+			})
+			if result.Status != tt.want {
+				t.Errorf("Status = %s, want %s (%s)", result.Status, tt.want, result.Message)
+			}
+			for _, key := range []string{"distance_meters", "outside_meters", "radius_meters"} {
+				if _, ok := result.Details[key]; ok {
+					t.Errorf("Details expose %s", key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var ErrNotICalendar = errors.New("not an iCalendar file")
+
+// ICalEvent is an all-day view of a VEVENT: StartsOn and EndsOn are the
+// first and last dates it covers.
+type ICalEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Categories  []string
+	StartsOn    time.Time
+	EndsOn      time.Time
+	// Recurring events carry an RRULE, which is not expanded
+	Recurring bool
+}
+
+// icalLine is one unfolded content line, e.g. DTSTART;VALUE=DATE:20250101.
+// Parameters are dropped: dates are read from the value alone.
+type icalLine struct {
+	name  string
+	value string
+}
+
+// ParseICalendar reads the VEVENTs of an iCalendar (RFC 5545) stream. Times
+// are reduced to dates in the time zone they were written in; events ending
+// at midnight do not cover the day they end on.
+func ParseICalendar(r io.Reader) ([]ICalEvent, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, ErrNotICalendar
+	}
+
+	var (
+		events  []ICalEvent
+		current *ICalEvent
+		depth   int
+		lineNo  int
+		hasEnd  bool
+	)
+	for _, raw := range lines {
+		lineNo++
+		line, ok := parseICalLine(raw)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case line.name == "BEGIN" && strings.EqualFold(line.value, "VEVENT"):
+			current = &ICalEvent{}
+			hasEnd = false
+			depth = 0
+			continue
+		case current == nil:
+			continue
+		case line.name == "BEGIN":
+			// Skip nested components such as VALARM
+			depth++
+			continue
+		case line.name == "END" && depth > 0:
+			depth--
+			continue
+		case depth > 0:
+			continue
+		case line.name == "END" && strings.EqualFold(line.value, "VEVENT"):
+			if current.StartsOn.IsZero() {
+				return nil, fmt.Errorf("line %d: event %q has no DTSTART", lineNo, current.Summary)
+			}
+			if !hasEnd || current.EndsOn.Before(current.StartsOn) {
+				current.EndsOn = current.StartsOn
+			}
+			events = append(events, *current)
+			current = nil
+			continue
+		}
+
+		switch line.name {
+		case "UID":
+			current.UID = line.value
+		case "SUMMARY":
+			current.Summary = unescapeICalText(line.value)
+		case "DESCRIPTION":
+			current.Description = unescapeICalText(line.value)
+		case "CATEGORIES":
+			for _, category := range splitICalList(line.value) {
+				if category = strings.TrimSpace(unescapeICalText(category)); category != "" {
+					current.Categories = append(current.Categories, category)
+				}
+			}
+		case "RRULE":
+			current.Recurring = true
+		case "DTSTART":
+			date, _, err := parseICalDate(line.value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			current.StartsOn = date
+		case "DTEND":
+			date, midnight, err := parseICalDate(line.value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			// DTEND is exclusive, so an all-day event or one ending at
+			// midnight ends the day before
+			if midnight {
+				date = date.AddDate(0, 0, -1)
+			}
+			current.EndsOn = date
+			hasEnd = true
+		}
+	}
+	return events, nil
+}
+
+// unfoldICalLines joins folded continuation lines, which start with a space
+// or tab, onto the line before them
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) == 0 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += text[1:]
+			continue
+		}
+		if text != "" {
+			lines = append(lines, text)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func parseICalLine(raw string) (icalLine, bool) {
+	// The value starts at the first colon outside a quoted parameter
+	inQuotes := false
+	colon := -1
+	for i, r := range raw {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icalLine{}, false
+	}
+
+	name, _, _ := strings.Cut(raw[:colon], ";")
+	return icalLine{name: strings.ToUpper(name), value: raw[colon+1:]}, true
+}
+
+// parseICalDate reads a DATE (20250101) or DATE-TIME (20250101T090000[Z])
+// value as a date, and reports whether it falls exactly at the start of
+// that day
+func parseICalDate(value string) (time.Time, bool, error) {
+	if len(value) < 8 {
+		return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+	}
+	if len(value) == 8 {
+		return date, true, nil
+	}
+	clock := strings.TrimSuffix(value[8:], "Z")
+	if len(clock) != 7 || clock[0] != 'T' {
+		return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+	}
+	return date, clock == "T000000", nil
+}
+
+// splitICalList splits a comma-separated value, leaving escaped commas (\,)
+// in place for unescapeICalText
+func splitICalList(value string) []string {
+	var (
+		parts []string
+		start int
+	)
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+func unescapeICalText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
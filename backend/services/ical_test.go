@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseICalendar(t *testing.T) {
+	calendar := func(lines ...string) string {
+		return strings.Join(append(append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...), "END:VCALENDAR"), "\r\n")
+	}
+	event := func(lines ...string) string {
+		return calendar(append(append([]string{"BEGIN:VEVENT"}, lines...), "END:VEVENT")...)
+	}
+
+	type want struct {
+		UID, Summary, Description string
+		Categories                []string
+		StartsOn, EndsOn          string
+		Recurring                 bool
+	}
+	tests := []struct {
+		name    string
+		input   string
+		want    []want
+		wantErr bool
+	}{
+		{
+			name:  "all-day event with exclusive DTEND",
+			input: event("UID:1", "SUMMARY:Winter break", "DTSTART;VALUE=DATE:20250101", "DTEND;VALUE=DATE:20250103"),
+			want:  []want{{UID: "1", Summary: "Winter break", StartsOn: "2025-01-01", EndsOn: "2025-01-02"}},
+		},
+		{
+			name:  "single all-day event",
+			input: event("SUMMARY:Holiday", "DTSTART;VALUE=DATE:20250501", "DTEND;VALUE=DATE:20250502"),
+			want:  []want{{Summary: "Holiday", StartsOn: "2025-05-01", EndsOn: "2025-05-01"}},
+		},
+		{
+			name:  "no DTEND covers one day",
+			input: event("SUMMARY:Holiday", "DTSTART;VALUE=DATE:20250501"),
+			want:  []want{{Summary: "Holiday", StartsOn: "2025-05-01", EndsOn: "2025-05-01"}},
+		},
+		{
+			name:  "timed event ending in the afternoon covers its end day",
+			input: event("SUMMARY:Exam", "DTSTART:20250310T090000Z", "DTEND:20250311T150000Z"),
+			want:  []want{{Summary: "Exam", StartsOn: "2025-03-10", EndsOn: "2025-03-11"}},
+		},
+		{
+			name:  "timed event ending at midnight does not cover its end day",
+			input: event("SUMMARY:Fair", "DTSTART;TZID=Europe/Berlin:20250310T090000", "DTEND;TZID=Europe/Berlin:20250312T000000"),
+			want:  []want{{Summary: "Fair", StartsOn: "2025-03-10", EndsOn: "2025-03-11"}},
+		},
+		{
+			name:  "DTEND before DTSTART is clamped",
+			input: event("SUMMARY:Typo", "DTSTART;VALUE=DATE:20250310", "DTEND;VALUE=DATE:20250301"),
+			want:  []want{{Summary: "Typo", StartsOn: "2025-03-10", EndsOn: "2025-03-10"}},
+		},
+		{
+			name: "folded lines, BOM and escaped text",
+			input: "\ufeff" + event("SUMMARY:Sports day\\, afternoon",
+				"DESCRIPTION:Line one\\nline",
+				"  two",
+				"DTSTART;VALUE=DATE:20250601"),
+			want: []want{{Summary: "Sports day, afternoon", Description: "Line one\nline two", StartsOn: "2025-06-01", EndsOn: "2025-06-01"}},
+		},
+		{
+			name:  "categories are split and trimmed",
+			input: event("SUMMARY:Break", "CATEGORIES:Holiday, School\\,Closed,,", "DTSTART;VALUE=DATE:20250601"),
+			want:  []want{{Summary: "Break", Categories: []string{"Holiday", "School,Closed"}, StartsOn: "2025-06-01", EndsOn: "2025-06-01"}},
+		},
+		{
+			name:  "RRULE marks the event recurring",
+			input: event("SUMMARY:Assembly", "DTSTART;VALUE=DATE:20250602", "RRULE:FREQ=WEEKLY;BYDAY=MO"),
+			want:  []want{{Summary: "Assembly", StartsOn: "2025-06-02", EndsOn: "2025-06-02", Recurring: true}},
+		},
+		{
+			name: "nested VALARM is skipped",
+			input: event("SUMMARY:Exam", "DTSTART;VALUE=DATE:20250610",
+				"BEGIN:VALARM", "DESCRIPTION:Reminder", "TRIGGER:-P1D", "END:VALARM"),
+			want: []want{{Summary: "Exam", StartsOn: "2025-06-10", EndsOn: "2025-06-10"}},
+		},
+		{
+			name: "several events, other components ignored",
+			input: calendar(
+				"BEGIN:VTIMEZONE", "TZID:Europe/Berlin", "END:VTIMEZONE",
+				"BEGIN:VEVENT", "SUMMARY:A", "DTSTART;VALUE=DATE:20250101", "END:VEVENT",
+				"BEGIN:VEVENT", "SUMMARY:B", "DTSTART;VALUE=DATE:20250201", "END:VEVENT"),
+			want: []want{
+				{Summary: "A", StartsOn: "2025-01-01", EndsOn: "2025-01-01"},
+				{Summary: "B", StartsOn: "2025-02-01", EndsOn: "2025-02-01"},
+			},
+		},
+		{
+			name:  "no events",
+			input: calendar(),
+			want:  nil,
+		},
+		{
+			name:    "missing DTSTART",
+			input:   event("SUMMARY:Broken"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid date",
+			input:   event("SUMMARY:Broken", "DTSTART:2025-01-01"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid time",
+			input:   event("SUMMARY:Broken", "DTSTART:20250101T0900"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := ParseICalendar(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []want
+			for _, e := range events {
+				got = append(got, want{
+					UID:         e.UID,
+					Summary:     e.Summary,
+					Description: e.Description,
+					Categories:  e.Categories,
+					StartsOn:    e.StartsOn.Format(dateLayout),
+					EndsOn:      e.EndsOn.Format(dateLayout),
+					Recurring:   e.Recurring,
+				})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseICalendarRejectsOtherFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"csv", "date,name\n2025-01-01,Holiday\n"},
+		{"vcard", "BEGIN:VCARD\r\nEND:VCARD\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseICalendar(strings.NewReader(tt.input)); !errors.Is(err, ErrNotICalendar) {
+				t.Errorf("err = %v, want %v", err, ErrNotICalendar)
+			}
+		})
+	}
+}
@@ -2,39 +2,98 @@ package services
 
 import (
 	"errors"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 
+	"smart_attendance_backend/config"
 	"smart_attendance_backend/models"
+	"smart_attendance_backend/utils"
 )
 
-// GenerateToken generates a JWT token for the given user.
-func GenerateToken(user models.User) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return "", errors.New("JWT secret not set in environment")
+// AdminTokenRole is the role claim carried by admin access tokens.
+const AdminTokenRole = "admin"
+
+// Claims are the claims carried by access tokens.
+type Claims struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+	// SessionID is the refresh token family of the login the token belongs to
+	SessionID string `json:"sid,omitempty"`
+	// TokenVersion must match User.TokenVersion (Admin.TokenVersion for admin
+	// tokens); bumping it revokes every token
+	TokenVersion int `json:"tv"`
+	jwt.RegisteredClaims
+}
+
+// IsRevokedFor reports whether the token was issued for a token version other
+// than tokenVersion, the holder's current one. Bumping the version revokes
+// every token issued before it.
+func (c *Claims) IsRevokedFor(tokenVersion int) bool {
+	return c.TokenVersion != tokenVersion
+}
+
+// GenerateToken generates a JWT token for the given user and login session.
+func GenerateToken(user models.User, sessionID string) (string, error) {
+	expiry := config.AppConfig.JWT.TokenExpiry
+	if expiry <= 0 {
+		expiry = 24 * time.Hour // default expiry
 	}
 
-	expiryStr := os.Getenv("JWT_EXPIRY_HOURS")
-	expiryHours, err := strconv.Atoi(expiryStr)
-	if err != nil {
-		expiryHours = 24 // default expiry
+	now := time.Now()
+	claims := Claims{
+		UserID:       user.ID,
+		Role:         string(user.Role),
+		SessionID:    sessionID,
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        utils.GenerateUUID(),
+			Issuer:    config.AppConfig.JWT.Issuer,
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		},
 	}
 
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"role":    string(user.Role),
-		"exp":     time.Now().Add(time.Hour * time.Duration(expiryHours)).Unix(),
+	return signAccessToken(claims)
+}
+
+// GenerateAdminToken generates a JWT token for an admin portal user.
+func GenerateAdminToken(admin models.Admin) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID:       admin.ID,
+		Role:         AdminTokenRole,
+		TokenVersion: admin.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        utils.GenerateUUID(),
+			Issuer:    config.AppConfig.JWT.Issuer,
+			Subject:   admin.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.AppConfig.JWT.TokenExpiry)),
+		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secret))
-	if err != nil {
-		return "", err
+	return signAccessToken(claims)
+}
+
+// ParseToken validates an access token and returns its claims.
+func ParseToken(tokenString string) (*Claims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{accessSigningAlgorithm()}),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer := config.AppConfig.JWT.Issuer; issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
 	}
 
-	return tokenString, nil
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, accessKeyFunc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" {
+		return nil, errors.New("token is missing user_id claim")
+	}
+	return claims, nil
 }
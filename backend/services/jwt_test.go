@@ -0,0 +1,103 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+func TestClaimsIsRevokedFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		issued  int
+		current int
+		want    bool
+	}{
+		{"current version", 0, 0, false},
+		{"version bumped after issue", 0, 1, true},
+		{"bumped several times", 2, 5, true},
+		{"issued for a version the holder never had", 3, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := Claims{TokenVersion: tt.issued}
+			if got := claims.IsRevokedFor(tt.current); got != tt.want {
+				t.Errorf("IsRevokedFor(%d) = %v, want %v", tt.current, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenClaims(t *testing.T) {
+	useKeys(t)
+	if err := LoadKeys(config.JWTConfig{Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		user      models.User
+		sessionID string
+	}{
+		{"student login", models.User{ID: "user-1", Role: models.RoleStudent}, "family-1"},
+		{"teacher after logging out everywhere", models.User{ID: "user-2", Role: models.RoleTeacher, TokenVersion: 3}, "family-2"},
+		{"no login session", models.User{ID: "user-3", Role: models.RoleStudent, TokenVersion: 1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := GenerateToken(tt.user, tt.sessionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := ParseToken(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != tt.user.ID || claims.Role != string(tt.user.Role) || claims.SessionID != tt.sessionID {
+				t.Errorf("claims = %s/%s/%s, want %s/%s/%s", claims.UserID, claims.Role, claims.SessionID, tt.user.ID, tt.user.Role, tt.sessionID)
+			}
+			if claims.IsRevokedFor(tt.user.TokenVersion) {
+				t.Errorf("token is revoked for the version it was issued with")
+			}
+			if !claims.IsRevokedFor(tt.user.TokenVersion + 1) {
+				t.Errorf("token survives a token version bump")
+			}
+			if claims.ID == "" {
+				t.Errorf("token has no jti")
+			}
+		})
+	}
+}
+
+func TestParseTokenRejectsTamperedVersion(t *testing.T) {
+	useKeys(t)
+	if err := LoadKeys(config.JWTConfig{Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	token, err := GenerateToken(models.User{ID: "user-1", Role: models.RoleStudent, TokenVersion: 1}, "family-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateToken(models.User{ID: "user-1", Role: models.RoleStudent, TokenVersion: 2}, "family-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts, otherParts := strings.Split(token, "."), strings.Split(other, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"payload of a newer token with the old signature", parts[0] + "." + otherParts[1] + "." + parts[2]},
+		{"signature stripped", parts[0] + "." + parts[1] + "."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseToken(tt.token); err == nil {
+				t.Error("tampered token was accepted")
+			}
+		})
+	}
+}
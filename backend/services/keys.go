@@ -0,0 +1,278 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"smart_attendance_backend/config"
+)
+
+// keySet holds the key used to sign access tokens and every public key that
+// is still accepted when verifying them.
+type keySet struct {
+	method       jwt.SigningMethod
+	signingKeyID string
+	signingKey   interface{}
+	// verificationKeys maps kid to public key; empty for HS256
+	verificationKeys map[string]crypto.PublicKey
+}
+
+var accessKeys *keySet
+
+// JWK is a single JSON Web Key as published on the JWKS endpoint.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeys prepares access token signing and verification keys from the JWT
+// configuration. It must be called once at startup after config.Load.
+func LoadKeys(cfg config.JWTConfig) error {
+	set := &keySet{verificationKeys: make(map[string]crypto.PublicKey)}
+
+	switch strings.ToUpper(cfg.SigningAlgorithm) {
+	case "", "HS256":
+		if cfg.Secret == "" {
+			return errors.New("JWT_SECRET is required for HS256 signing")
+		}
+		set.method = jwt.SigningMethodHS256
+		set.signingKey = []byte(cfg.Secret)
+		accessKeys = set
+		return nil
+	case "RS256":
+		set.method = jwt.SigningMethodRS256
+	case "EDDSA":
+		set.method = jwt.SigningMethodEdDSA
+	default:
+		return fmt.Errorf("unsupported JWT signing algorithm %q", cfg.SigningAlgorithm)
+	}
+
+	if cfg.PrivateKeyFile == "" {
+		return fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s signing", set.method.Alg())
+	}
+	privateKey, err := loadPrivateKey(cfg.PrivateKeyFile)
+	if err != nil {
+		return err
+	}
+	if err := checkKeyMatchesMethod(privateKey.Public(), set.method); err != nil {
+		return fmt.Errorf("%s: %w", cfg.PrivateKeyFile, err)
+	}
+
+	kid := cfg.KeyID
+	if kid == "" {
+		kid, err = keyThumbprint(privateKey.Public())
+		if err != nil {
+			return err
+		}
+	}
+	set.signingKeyID = kid
+	set.signingKey = privateKey
+	set.verificationKeys[kid] = privateKey.Public()
+
+	for verifyKID, path := range cfg.VerificationKeyFiles {
+		if _, exists := set.verificationKeys[verifyKID]; exists {
+			return fmt.Errorf("duplicate JWT key id %q", verifyKID)
+		}
+		publicKey, err := loadPublicKey(path)
+		if err != nil {
+			return err
+		}
+		if err := checkKeyMatchesMethod(publicKey, set.method); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		set.verificationKeys[verifyKID] = publicKey
+	}
+
+	accessKeys = set
+	return nil
+}
+
+// JWKS returns the public verification keys. It is empty when tokens are
+// signed with a shared HS256 secret.
+func JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if accessKeys == nil {
+		return set
+	}
+	for kid, key := range accessKeys.verificationKeys {
+		jwk, err := toJWK(kid, key, accessKeys.method.Alg())
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func signAccessToken(claims jwt.Claims) (string, error) {
+	if accessKeys == nil {
+		return "", errors.New("JWT signing keys not loaded")
+	}
+	token := jwt.NewWithClaims(accessKeys.method, claims)
+	if accessKeys.signingKeyID != "" {
+		token.Header["kid"] = accessKeys.signingKeyID
+	}
+	return token.SignedString(accessKeys.signingKey)
+}
+
+// accessKeyFunc resolves the verification key for an access token by its kid.
+func accessKeyFunc(token *jwt.Token) (interface{}, error) {
+	if accessKeys == nil {
+		return nil, errors.New("JWT signing keys not loaded")
+	}
+	if accessKeys.method == jwt.SigningMethodHS256 {
+		return accessKeys.signingKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := accessKeys.verificationKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func accessSigningAlgorithm() string {
+	if accessKeys == nil {
+		return jwt.SigningMethodHS256.Alg()
+	}
+	return accessKeys.method.Alg()
+}
+
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported private key type", path)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s: expected a PKCS#8 or PKCS#1 private key", path)
+}
+
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s: expected a PKIX or PKCS#1 public key", path)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+func checkKeyMatchesMethod(key crypto.PublicKey, method jwt.SigningMethod) error {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if method == jwt.SigningMethodRS256 {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if method == jwt.SigningMethodEdDSA {
+			return nil
+		}
+	}
+	return fmt.Errorf("key type %T cannot be used with %s", key, method.Alg())
+}
+
+func toJWK(kid string, key crypto.PublicKey, alg string) (JWK, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: alg,
+			N:         base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: alg,
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	}
+	return JWK{}, fmt.Errorf("unsupported public key type %T", key)
+}
+
+// keyThumbprint derives a kid from the RFC 7638 JWK thumbprint of the key.
+func keyThumbprint(key crypto.PublicKey) (string, error) {
+	jwk, err := toJWK("", key, "")
+	if err != nil {
+		return "", err
+	}
+
+	// Members must be in lexicographic order with no whitespace
+	var members interface{}
+	if jwk.KeyType == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
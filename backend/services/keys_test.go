@@ -0,0 +1,329 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"smart_attendance_backend/config"
+)
+
+// writeKeyFiles writes key as PKCS#8 and PKIX PEM files named after name
+func writeKeyFiles(t *testing.T, dir, name string, key crypto.Signer) (privatePath, publicPath string) {
+	t.Helper()
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath = filepath.Join(dir, name+".pem")
+	publicPath = filepath.Join(dir, name+".pub.pem")
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+// useKeys restores the loaded access token keys and JWT config after the test
+func useKeys(t *testing.T) {
+	t.Helper()
+	previousKeys, previousConfig := accessKeys, config.AppConfig.JWT
+	t.Cleanup(func() {
+		accessKeys = previousKeys
+		config.AppConfig.JWT = previousConfig
+	})
+	config.AppConfig.JWT.Issuer = ""
+	config.AppConfig.JWT.TokenExpiry = time.Hour
+}
+
+func testAccessClaims() Claims {
+	now := time.Now()
+	return Claims{
+		UserID: "user-1",
+		Role:   "student",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	useKeys(t)
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPrivate, rsaPublic := writeKeyFiles(t, dir, "rsa", rsaKey)
+	edPrivate, edPublic := writeKeyFiles(t, dir, "ed", edKey)
+
+	tests := []struct {
+		name    string
+		cfg     config.JWTConfig
+		wantErr bool
+	}{
+		{"HS256", config.JWTConfig{Secret: "secret"}, false},
+		{"HS256 without a secret", config.JWTConfig{SigningAlgorithm: "HS256"}, true},
+		{"unsupported algorithm", config.JWTConfig{SigningAlgorithm: "ES256"}, true},
+		{"RS256", config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: rsaPrivate}, false},
+		{"EdDSA", config.JWTConfig{SigningAlgorithm: "EdDSA", PrivateKeyFile: edPrivate}, false},
+		{"RS256 without a key file", config.JWTConfig{SigningAlgorithm: "RS256"}, true},
+		{"missing key file", config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: filepath.Join(dir, "missing.pem")}, true},
+		{"public key as private key", config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: rsaPublic}, true},
+		{"key does not match the algorithm", config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: edPrivate}, true},
+		{"verification key does not match the algorithm", config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: rsaPrivate,
+			VerificationKeyFiles: map[string]string{"old": edPublic}}, true},
+		{"verification key reuses the signing kid", config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: rsaPrivate, KeyID: "current",
+			VerificationKeyFiles: map[string]string{"current": rsaPublic}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := LoadKeys(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccessTokenKeyRotation(t *testing.T) {
+	useKeys(t)
+	dir := t.TempDir()
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPrivate, oldPublic := writeKeyFiles(t, dir, "old", oldKey)
+	newPrivate, _ := writeKeyFiles(t, dir, "new", newKey)
+
+	before := config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: oldPrivate, KeyID: "2024"}
+	during := config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: newPrivate, KeyID: "2025",
+		VerificationKeyFiles: map[string]string{"2024": oldPublic}}
+	after := config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: newPrivate, KeyID: "2025"}
+	hs256 := config.JWTConfig{Secret: "secret"}
+
+	sign := func(cfg config.JWTConfig) string {
+		if err := LoadKeys(cfg); err != nil {
+			t.Fatal(err)
+		}
+		token, err := signAccessToken(testAccessClaims())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	oldToken, newToken, hsToken := sign(before), sign(during), sign(hs256)
+
+	// A token signed by the new key but naming a kid that is not configured
+	unknownKID := jwt.NewWithClaims(jwt.SigningMethodRS256, testAccessClaims())
+	unknownKID.Header["kid"] = "2023"
+	unknownKIDToken, err := unknownKID.SignedString(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		cfg       config.JWTConfig
+		token     string
+		wantValid bool
+	}{
+		{"old token while the old key is current", before, oldToken, true},
+		{"old token during rotation", during, oldToken, true},
+		{"new token during rotation", during, newToken, true},
+		{"old token after the old key is retired", after, oldToken, false},
+		{"new token after rotation", after, newToken, true},
+		{"unknown kid", during, unknownKIDToken, false},
+		{"HS256 token when RS256 is configured", during, hsToken, false},
+		{"RS256 token when HS256 is configured", hs256, newToken, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := LoadKeys(tt.cfg); err != nil {
+				t.Fatal(err)
+			}
+			claims, err := ParseToken(tt.token)
+			if (err == nil) != tt.wantValid {
+				t.Fatalf("err = %v, want valid %v", err, tt.wantValid)
+			}
+			if err == nil && claims.UserID != "user-1" {
+				t.Errorf("UserID = %q, want user-1", claims.UserID)
+			}
+		})
+	}
+}
+
+func TestSignAccessTokenKeyID(t *testing.T) {
+	useKeys(t)
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, _ := writeKeyFiles(t, dir, "rsa", key)
+	thumbprint, err := keyThumbprint(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.JWTConfig
+		wantKID interface{}
+	}{
+		{"configured kid", config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: private, KeyID: "2025"}, "2025"},
+		{"thumbprint when no kid is configured", config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: private}, thumbprint},
+		{"no kid for HS256", config.JWTConfig{Secret: "secret"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := LoadKeys(tt.cfg); err != nil {
+				t.Fatal(err)
+			}
+			signed, err := signAccessToken(testAccessClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, _, err := jwt.NewParser().ParseUnverified(signed, &Claims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := token.Header["kid"]; got != tt.wantKID {
+				t.Errorf("kid = %v, want %v", got, tt.wantKID)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	useKeys(t)
+	dir := t.TempDir()
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, oldPublic := writeKeyFiles(t, dir, "old", oldKey)
+	newPrivate, _ := writeKeyFiles(t, dir, "new", newKey)
+	edPrivate, _ := writeKeyFiles(t, dir, "ed", edKey)
+
+	rsaJWK := func(kid string, key *rsa.PrivateKey) JWK {
+		return JWK{KeyType: "RSA", KeyID: kid, Use: "sig", Algorithm: "RS256",
+			N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()), E: "AQAB"}
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.JWTConfig
+		want map[string]JWK
+	}{
+		{"HS256 publishes nothing", config.JWTConfig{Secret: "secret"}, map[string]JWK{}},
+		{
+			name: "RS256 with a previous key",
+			cfg: config.JWTConfig{SigningAlgorithm: "RS256", PrivateKeyFile: newPrivate, KeyID: "2025",
+				VerificationKeyFiles: map[string]string{"2024": oldPublic}},
+			want: map[string]JWK{"2025": rsaJWK("2025", newKey), "2024": rsaJWK("2024", oldKey)},
+		},
+		{
+			name: "EdDSA",
+			cfg:  config.JWTConfig{SigningAlgorithm: "EdDSA", PrivateKeyFile: edPrivate, KeyID: "ed"},
+			want: map[string]JWK{"ed": {KeyType: "OKP", KeyID: "ed", Use: "sig", Algorithm: "EdDSA", Curve: "Ed25519",
+				X: base64.RawURLEncoding.EncodeToString(edPublic)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := LoadKeys(tt.cfg); err != nil {
+				t.Fatal(err)
+			}
+			set := JWKS()
+			if set.Keys == nil {
+				t.Fatal("Keys is nil, want an empty list")
+			}
+			if len(set.Keys) != len(tt.want) {
+				t.Fatalf("got %d keys, want %d", len(set.Keys), len(tt.want))
+			}
+			for _, got := range set.Keys {
+				if got != tt.want[got.KeyID] {
+					t.Errorf("key %q = %+v, want %+v", got.KeyID, got, tt.want[got.KeyID])
+				}
+			}
+		})
+	}
+}
+
+func TestKeyThumbprint(t *testing.T) {
+	decode := func(value string) []byte {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name string
+		key  crypto.PublicKey
+		want string
+	}{
+		{
+			// RFC 7638, section 3.1
+			name: "RSA",
+			key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(decode("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")),
+				E: 65537,
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037, appendix A.3
+			name: "Ed25519",
+			key:  ed25519.PublicKey(decode("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")),
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keyThumbprint(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("thumbprint = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+// RecordFailedLogin counts a failed password or OTP attempt against the user,
+// locking the account when the configured threshold is reached. The counters
+// are re-read under a row lock so concurrent failures all count. Every
+// lockout is written to the audit log. It returns true when the account is
+// locked, including by a concurrent failure.
+func RecordFailedLogin(user *models.User, reason, ipAddress string) (bool, error) {
+	cfg := config.AppConfig.Security
+	db := models.GetDB()
+	locked, alreadyLocked := false, false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "failed_logins", "lockout_count", "locked_until").
+			Where("id = ?", user.ID).First(&current).Error; err != nil {
+			return err
+		}
+		user.FailedLogins, user.LockoutCount, user.LockedUntil = current.FailedLogins, current.LockoutCount, current.LockedUntil
+		if user.IsLocked() {
+			alreadyLocked = true
+			return nil
+		}
+
+		locked = user.RegisterFailedLogin(cfg.MaxFailedLogins, cfg.LockoutDuration, cfg.MaxLockoutDuration)
+		return tx.Model(user).Select("failed_logins", "lockout_count", "locked_until").Updates(user).Error
+	})
+	if err != nil || alreadyLocked {
+		return alreadyLocked, err
+	}
+
+	if locked {
+		audit := models.AuditLog{UserID: user.ID, Action: "account_locked", IPAddress: ipAddress}
+		audit.SetDetails(map[string]interface{}{
+			"reason":        reason,
+			"locked_until":  user.LockedUntil,
+			"lockout_count": user.LockoutCount,
+		})
+		if err := db.Create(&audit).Error; err != nil {
+			return locked, err
+		}
+	}
+	return locked, nil
+}
+
+// ClearFailedLogins resets the failure counters after a successful sign-in.
+func ClearFailedLogins(user *models.User) error {
+	if user.FailedLogins == 0 && user.LockoutCount == 0 && user.LockedUntil == nil {
+		return nil
+	}
+	user.ResetFailedLogins()
+	return models.GetDB().Model(user).Select("failed_logins", "lockout_count", "locked_until").Updates(user).Error
+}
+
+// LockoutRemaining returns how long the user stays locked out.
+func LockoutRemaining(user *models.User) time.Duration {
+	if !user.IsLocked() {
+		return 0
+	}
+	return time.Until(*user.LockedUntil)
+}
+
+// unknownLogin counts failed sign-ins for an identifier that matches no
+// account, with the same backoff as models.User.RegisterFailedLogin
+type unknownLogin struct {
+	failures    int
+	lockouts    int
+	lockedUntil time.Time
+	updatedAt   time.Time
+}
+
+// register counts a failure at now and returns true when it locked the
+// identifier.
+func (l *unknownLogin) register(now time.Time, maxFailures int, lockout, maxLockout time.Duration) bool {
+	l.failures++
+	l.updatedAt = now
+	if maxFailures <= 0 || l.failures < maxFailures {
+		return false
+	}
+	l.lockedUntil = now.Add(models.LockoutDuration(l.lockouts, lockout, maxLockout))
+	l.lockouts++
+	l.failures = 0
+	return true
+}
+
+// remaining returns how long the identifier stays locked out at now.
+func (l *unknownLogin) remaining(now time.Time) time.Duration {
+	if !now.Before(l.lockedUntil) {
+		return 0
+	}
+	return l.lockedUntil.Sub(now)
+}
+
+// unknownLogins applies the lockout policy to sign-in identifiers that match
+// no account, so a lockout does not reveal that an account exists. Like
+// MemoryRateLimitStore it only sees this instance's traffic.
+var unknownLogins = struct {
+	sync.Mutex
+	entries   map[string]*unknownLogin
+	lastSweep time.Time
+}{entries: make(map[string]*unknownLogin), lastSweep: time.Now()}
+
+// RecordUnknownLogin counts a failed sign-in for an identifier that matches
+// no account and returns how long it is now locked out, or 0.
+func RecordUnknownLogin(identifier string) time.Duration {
+	cfg := config.AppConfig.Security
+	key := strings.ToLower(strings.TrimSpace(identifier))
+
+	unknownLogins.Lock()
+	defer unknownLogins.Unlock()
+	now := time.Now()
+	sweepUnknownLogins(now, cfg.MaxLockoutDuration)
+
+	entry, ok := unknownLogins.entries[key]
+	if !ok {
+		entry = &unknownLogin{}
+		unknownLogins.entries[key] = entry
+	}
+	if entry.register(now, cfg.MaxFailedLogins, cfg.LockoutDuration, cfg.MaxLockoutDuration) {
+		return entry.remaining(now)
+	}
+	return 0
+}
+
+// UnknownLoginLockout returns how long an identifier that matches no account
+// stays locked out.
+func UnknownLoginLockout(identifier string) time.Duration {
+	unknownLogins.Lock()
+	defer unknownLogins.Unlock()
+	if entry, ok := unknownLogins.entries[strings.ToLower(strings.TrimSpace(identifier))]; ok {
+		return entry.remaining(time.Now())
+	}
+	return 0
+}
+
+// sweepUnknownLogins drops entries idle for longer than the longest lockout,
+// at most once a minute, so memory stays bounded.
+func sweepUnknownLogins(now time.Time, idle time.Duration) {
+	if now.Sub(unknownLogins.lastSweep) < time.Minute {
+		return
+	}
+	for key, entry := range unknownLogins.entries {
+		if entry.remaining(now) == 0 && now.Sub(entry.updatedAt) > idle {
+			delete(unknownLogins.entries, key)
+		}
+	}
+	unknownLogins.lastSweep = now
+}
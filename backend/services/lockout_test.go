@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"smart_attendance_backend/models"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous int
+		want     time.Duration
+	}{
+		{"first lockout", 0, 5 * time.Minute},
+		{"second lockout doubles", 1, 10 * time.Minute},
+		{"third lockout doubles again", 2, 20 * time.Minute},
+		{"capped", 4, time.Hour},
+		{"stays capped", 50, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := models.LockoutDuration(tt.previous, 5*time.Minute, time.Hour); got != tt.want {
+				t.Errorf("LockoutDuration(%d) = %s, want %s", tt.previous, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownLoginRegister(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name          string
+		maxFailures   int
+		failures      int
+		wantLocked    bool
+		wantRemaining time.Duration
+	}{
+		{"below the threshold", 3, 2, false, 0},
+		{"threshold locks", 3, 3, true, time.Minute},
+		{"second lockout doubles", 3, 6, true, 2 * time.Minute},
+		{"lockouts are capped", 3, 15, true, 5 * time.Minute},
+		{"lockout disabled", 0, 10, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entry unknownLogin
+			locked := false
+			for i := 0; i < tt.failures; i++ {
+				locked = entry.register(start, tt.maxFailures, time.Minute, 5*time.Minute)
+			}
+			if locked != tt.wantLocked {
+				t.Errorf("locked = %v, want %v", locked, tt.wantLocked)
+			}
+			if got := entry.remaining(start); got != tt.wantRemaining {
+				t.Errorf("remaining = %s, want %s", got, tt.wantRemaining)
+			}
+			if got := entry.remaining(start.Add(tt.wantRemaining)); got != 0 {
+				t.Errorf("remaining once the lockout ends = %s, want 0", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+var (
+	ErrOTPNotFound    = errors.New("OTP record not found")
+	ErrOTPExpired     = errors.New("OTP has expired")
+	ErrOTPInvalid     = errors.New("invalid OTP")
+	ErrOTPMaxAttempts = errors.New("maximum OTP attempts exceeded")
+)
+
+// OTPThrottledError is returned by SendOTP when a code was requested too soon
+// after the previous one or too often in a day.
+type OTPThrottledError struct {
+	RetryAfter        time.Duration
+	DailyLimitReached bool
+}
+
+func (e *OTPThrottledError) Error() string {
+	if e.DailyLimitReached {
+		return "daily OTP limit reached"
+	}
+	return fmt.Sprintf("OTP requested too soon, retry in %s", e.RetryAfter)
+}
+
+// OTPStrategy issues one-time passwords to a user's phone and checks them.
+// Every strategy tracks outstanding codes in models.OTPVerification so expiry
+// and attempt limits are enforced the same way regardless of who generates
+// the code. Check runs onVerified, if given, in the transaction that spends
+// the code, so the code is only used up if what it authorises is saved too.
+type OTPStrategy interface {
+	Name() string
+	Send(ctx context.Context, user models.User, purpose models.OTPPurpose) error
+	Check(ctx context.Context, user models.User, purpose models.OTPPurpose, code string, onVerified func(tx *gorm.DB) error) error
+}
+
+// Bounds applied to config.OTPConfig.Length
+const (
+	minOTPLength = 4
+	maxOTPLength = 10
+)
+
+var otpStrategy OTPStrategy = &LocalOTPStrategy{}
+
+// InitOTP selects the OTP strategy named in the configuration.
+func InitOTP(otpCfg config.OTPConfig, smsCfg config.SMSConfig) error {
+	switch strings.ToLower(otpCfg.Strategy) {
+	case "local", "":
+		otpStrategy = &LocalOTPStrategy{}
+	case "twilio_verify":
+		if otpCfg.VerifyServiceSID == "" || smsCfg.TwilioAccountSID == "" || smsCfg.TwilioAuthToken == "" {
+			return errors.New("Twilio Verify requires TWILIO_VERIFY_SERVICE_SID, TWILIO_ACCOUNT_SID and TWILIO_AUTH_TOKEN")
+		}
+		otpStrategy = &TwilioVerifyStrategy{
+			BaseURL:    otpCfg.VerifyBaseURL,
+			ServiceSID: otpCfg.VerifyServiceSID,
+			AccountSID: smsCfg.TwilioAccountSID,
+			AuthToken:  smsCfg.TwilioAuthToken,
+			Client:     &http.Client{Timeout: smsCfg.Timeout},
+		}
+	default:
+		return fmt.Errorf("unknown OTP strategy %q", otpCfg.Strategy)
+	}
+	log.Printf("OTP strategy: %s", otpStrategy.Name())
+	return nil
+}
+
+// SetOTPStrategy replaces the strategy used by SendOTP and CheckOTP.
+func SetOTPStrategy(strategy OTPStrategy) {
+	otpStrategy = strategy
+}
+
+// SendOTP issues a code for the purpose through the configured strategy,
+// enforcing the resend cooldown and daily cap first.
+func SendOTP(ctx context.Context, user models.User, purpose models.OTPPurpose) error {
+	if err := checkOTPThrottle(user, purpose); err != nil {
+		return err
+	}
+	return otpStrategy.Send(ctx, user, purpose)
+}
+
+// CheckOTP verifies a code for the purpose through the configured strategy.
+// onVerified, if not nil, runs in the same transaction that marks the code
+// used; if it fails the code stays unused.
+func CheckOTP(ctx context.Context, user models.User, purpose models.OTPPurpose, code string, onVerified func(tx *gorm.DB) error) error {
+	return otpStrategy.Check(ctx, user, purpose, code, onVerified)
+}
+
+func checkOTPThrottle(user models.User, purpose models.OTPPurpose) error {
+	cfg := config.AppConfig.OTP
+	db := models.GetDB()
+	now := time.Now()
+
+	var last models.OTPVerification
+	err := db.Where("user_id = ? AND purpose = ?", user.ID, purpose).Order("created_at desc").First(&last).Error
+	if err == nil && cfg.ResendCooldown > 0 {
+		if wait := last.CreatedAt.Add(cfg.ResendCooldown).Sub(now); wait > 0 {
+			return &OTPThrottledError{RetryAfter: wait}
+		}
+	}
+
+	if cfg.DailyLimit > 0 {
+		var sent []models.OTPVerification
+		if err := db.Select("created_at").
+			Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, purpose, now.Add(-24*time.Hour)).
+			Order("created_at asc").Find(&sent).Error; err != nil {
+			return err
+		}
+		if len(sent) >= cfg.DailyLimit {
+			// The oldest code in the window has to age out before another is allowed
+			return &OTPThrottledError{
+				RetryAfter:        sent[len(sent)-cfg.DailyLimit].CreatedAt.Add(24 * time.Hour).Sub(now),
+				DailyLimitReached: true,
+			}
+		}
+	}
+	return nil
+}
+
+// LocalOTPStrategy generates codes itself, stores their HMAC in
+// otp_verifications and delivers them through the configured SMS provider.
+type LocalOTPStrategy struct{}
+
+func (s *LocalOTPStrategy) Name() string {
+	return "local"
+}
+
+func (s *LocalOTPStrategy) Send(ctx context.Context, user models.User, purpose models.OTPPurpose) error {
+	code, err := GenerateOTPCode(config.AppConfig.OTP.Length)
+	if err != nil {
+		return err
+	}
+
+	if err := issueOTPRecord(user, purpose, HashOTPCode(code), false); err != nil {
+		return err
+	}
+
+	return SendSMS(user.Phone, otpMessage(purpose, code))
+}
+
+func (s *LocalOTPStrategy) Check(ctx context.Context, user models.User, purpose models.OTPPurpose, code string, onVerified func(tx *gorm.DB) error) error {
+	return checkOutstandingOTP(user, purpose, func(otpRec *models.OTPVerification) (bool, error) {
+		return OTPCodeMatches(otpRec.OTPCode, code), nil
+	}, onVerified)
+}
+
+// GenerateOTPCode returns a uniformly random numeric code of the given length
+// using crypto/rand.
+func GenerateOTPCode(length int) (string, error) {
+	if length < minOTPLength {
+		length = minOTPLength
+	}
+	if length > maxOTPLength {
+		length = maxOTPLength
+	}
+
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", length, n), nil
+}
+
+// HashOTPCode returns the hex HMAC-SHA256 of a code as stored in
+// OTPVerification.OTPCode.
+func HashOTPCode(code string) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.OTP.HashSecret))
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// OTPCodeMatches compares a submitted code against a stored hash in constant time.
+func OTPCodeMatches(storedHash, code string) bool {
+	if storedHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(storedHash), []byte(HashOTPCode(code))) == 1
+}
+
+// otpMessage builds the SMS text for a code
+func otpMessage(purpose models.OTPPurpose, code string) string {
+	switch purpose {
+	case models.OTPPurposePasswordReset:
+		return "Your password reset code is: " + code
+	case models.OTPPurposePhoneChange:
+		return "Your phone number change code is: " + code
+	case models.OTPPurposeSensitiveAction:
+		return "Your confirmation code is: " + code
+	}
+	return "Your OTP code is: " + code
+}
+
+// issueOTPRecord stores a new outstanding OTP for the user and invalidates any
+// code issued before it for the same purpose, so only the latest code can be
+// used. With allPurposes set, outstanding codes for every purpose are
+// invalidated, for providers that only keep one pending code per phone.
+func issueOTPRecord(user models.User, purpose models.OTPPurpose, codeHash string, allPurposes bool) error {
+	return models.GetDB().Transaction(func(tx *gorm.DB) error {
+		outstanding := tx.Model(&models.OTPVerification{}).
+			Where("user_id = ? AND verified = ? AND invalidated_at IS NULL", user.ID, false)
+		if !allPurposes {
+			outstanding = outstanding.Where("purpose = ?", purpose)
+		}
+		if err := outstanding.Update("invalidated_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		otpRec := models.OTPVerification{
+			UserID:    user.ID,
+			Purpose:   purpose,
+			OTPCode:   codeHash,
+			ExpiresAt: time.Now().Add(config.AppConfig.OTP.Expiry),
+		}
+		return tx.Create(&otpRec).Error
+	})
+}
+
+// checkOutstandingOTP enforces expiry and attempt limits on the user's latest
+// unverified OTP for the purpose, then asks match whether the submitted code
+// is correct. Each check spends an attempt before the code is compared, with
+// an atomic update, so concurrent guesses cannot exceed the limit. A correct
+// code is marked used together with onVerified; if a concurrent check used or
+// replaced it first, ErrOTPNotFound is returned.
+func checkOutstandingOTP(user models.User, purpose models.OTPPurpose, match func(*models.OTPVerification) (bool, error), onVerified func(tx *gorm.DB) error) error {
+	db := models.GetDB()
+	var otpRecord models.OTPVerification
+	if err := db.Where("user_id = ? AND purpose = ? AND verified = ? AND invalidated_at IS NULL", user.ID, purpose, false).Order("created_at desc").First(&otpRecord).Error; err != nil {
+		return ErrOTPNotFound
+	}
+
+	if otpRecord.IsExpired() {
+		return ErrOTPExpired
+	}
+
+	maxAttempts := config.AppConfig.OTP.MaxAttempts
+	if otpRecord.HasExceededMaxAttempts(maxAttempts) {
+		return ErrOTPMaxAttempts
+	}
+
+	spent := db.Model(&models.OTPVerification{}).
+		Where("id = ? AND verified = ? AND invalidated_at IS NULL AND attempts < ?", otpRecord.ID, false, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if spent.Error != nil {
+		return spent.Error
+	}
+	if spent.RowsAffected == 0 {
+		// Another check spent the last attempt, used the code or replaced it
+		if err := db.First(&otpRecord, "id = ?", otpRecord.ID).Error; err == nil &&
+			!otpRecord.Verified && !otpRecord.IsInvalidated() && otpRecord.HasExceededMaxAttempts(maxAttempts) {
+			return ErrOTPMaxAttempts
+		}
+		return ErrOTPNotFound
+	}
+	otpRecord.IncrementAttempts()
+
+	ok, err := match(&otpRecord)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if otpRecord.HasExceededMaxAttempts(maxAttempts) {
+			return ErrOTPMaxAttempts
+		}
+		return ErrOTPInvalid
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&otpRecord).Where("verified = ? AND invalidated_at IS NULL", false).Update("verified", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOTPNotFound
+		}
+		otpRecord.MarkAsVerified()
+		if onVerified == nil {
+			return nil
+		}
+		return onVerified(tx)
+	})
+}
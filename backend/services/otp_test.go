@@ -0,0 +1,76 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"smart_attendance_backend/config"
+)
+
+func TestGenerateOTPCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		length  int
+		wantLen int
+	}{
+		{"configured length", 6, 6},
+		{"too short is raised to the minimum", 2, minOTPLength},
+		{"unset is raised to the minimum", 0, minOTPLength},
+		{"too long is capped", 20, maxOTPLength},
+		{"maximum", maxOTPLength, maxOTPLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				code, err := GenerateOTPCode(tt.length)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(code) != tt.wantLen {
+					t.Fatalf("code %q has length %d, want %d", code, len(code), tt.wantLen)
+				}
+				if strings.Trim(code, "0123456789") != "" {
+					t.Fatalf("code %q is not numeric", code)
+				}
+			}
+		})
+	}
+}
+
+func TestOTPCodeMatches(t *testing.T) {
+	previous := config.AppConfig.OTP
+	t.Cleanup(func() { config.AppConfig.OTP = previous })
+	config.AppConfig.OTP.HashSecret = "hash-secret"
+
+	stored := HashOTPCode("012345")
+	config.AppConfig.OTP.HashSecret = "other-secret"
+	otherSecret := HashOTPCode("012345")
+	config.AppConfig.OTP.HashSecret = "hash-secret"
+
+	tests := []struct {
+		name   string
+		stored string
+		code   string
+		want   bool
+	}{
+		{"correct code", stored, "012345", true},
+		{"wrong code", stored, "012346", false},
+		{"leading zero dropped", stored, "12345", false},
+		{"code padded with spaces", stored, " 012345", false},
+		{"empty code", stored, "", false},
+		{"no stored hash", "", "", false},
+		{"hashed with another secret", otherSecret, "012345", false},
+		{"plain code stored instead of a hash", "012345", "012345", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OTPCodeMatches(tt.stored, tt.code); got != tt.want {
+				t.Errorf("OTPCodeMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if stored == "012345" || len(stored) != 64 {
+		t.Errorf("stored hash %q is not a hex HMAC-SHA256", stored)
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+var (
+	ErrQRTokenInvalid  = errors.New("QR token is invalid")
+	ErrQRTokenExpired  = errors.New("QR token has expired")
+	ErrQRTokenReplayed = errors.New("QR token has already been used")
+)
+
+// qrMACBytes is how much of the HMAC is kept in the token, to keep the QR
+// code small enough to scan from the back of a lecture hall
+const qrMACBytes = 16
+
+// QRCode is the token shown on the teacher's screen for one rotation step.
+type QRCode struct {
+	Token     string
+	Step      int64
+	ExpiresAt time.Time
+}
+
+// NewQRSecret returns a random per-session secret for signing QR tokens.
+func NewQRSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// CurrentQRCode returns the session's QR token for the rotation step at now.
+// Tokens have the form <session_id>.<step>.<mac>.
+func CurrentQRCode(session *models.AttendanceSession, now time.Time) QRCode {
+	rotation := qrRotation()
+	step := now.Unix() / int64(rotation.Seconds())
+	return QRCode{
+		Token:     session.ID + "." + strconv.FormatInt(step, 10) + "." + qrMAC(session, step),
+		Step:      step,
+		ExpiresAt: time.Unix((step+1)*int64(rotation.Seconds()), 0),
+	}
+}
+
+// VerifyQRToken checks the token was signed for the session and is from the
+// current rotation step or one of the configured grace steps before it. It
+// returns the token's step.
+func VerifyQRToken(session *models.AttendanceSession, token string, now time.Time) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != session.ID {
+		return 0, ErrQRTokenInvalid
+	}
+	step, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrQRTokenInvalid
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(qrMAC(session, step))) {
+		return 0, ErrQRTokenInvalid
+	}
+
+	current := now.Unix() / int64(qrRotation().Seconds())
+	if step > current || step < current-int64(config.AppConfig.Attendance.QRGraceSteps) {
+		return 0, ErrQRTokenExpired
+	}
+	return step, nil
+}
+
+// QRTokenUsed reports whether the student has already spent a rotation step.
+func QRTokenUsed(tx *gorm.DB, sessionID, studentID string, step int64) (bool, error) {
+	var count int64
+	err := tx.Model(&models.QRTokenUse{}).
+		Where("session_id = ? AND student_id = ? AND step = ?", sessionID, studentID, step).Count(&count).Error
+	return count > 0, err
+}
+
+// ConsumeQRToken records the student's use of a rotation step. Each step can
+// be presented once per student, so a captured token cannot be retried. It
+// is called when the mark is stored, so a mark that fails another check
+// leaves the step usable.
+func ConsumeQRToken(tx *gorm.DB, sessionID, studentID string, step int64) error {
+	use := models.QRTokenUse{SessionID: sessionID, StudentID: studentID, Step: step}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&use)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrQRTokenReplayed
+	}
+	return nil
+}
+
+func qrMAC(session *models.AttendanceSession, step int64) string {
+	mac := hmac.New(sha256.New, []byte(session.QRSecret))
+	mac.Write([]byte(session.ID + "." + strconv.FormatInt(step, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:qrMACBytes])
+}
+
+func qrRotation() time.Duration {
+	rotation := config.AppConfig.Attendance.QRRotation
+	if rotation < time.Second {
+		return time.Second
+	}
+	return rotation
+}
+
+// QRTokenVerifier requires a fresh, unreplayed QR token for sessions started
+// in QR mode and is skipped for other sessions. The step is recorded on the
+// attempt and only spent once the mark is stored.
+type QRTokenVerifier struct{}
+
+func (QRTokenVerifier) Name() string { return CheckQRToken }
+
+func (QRTokenVerifier) Verify(ctx context.Context, attempt *MarkAttempt) CheckResult {
+	session := attempt.Session
+	if !session.QRRequired {
+		return CheckResult{Status: CheckSkipped, Message: "QR code not required for this session"}
+	}
+	if attempt.QRToken == "" {
+		return CheckResult{Status: CheckFailed, Message: "Scan the QR code on the teacher's screen"}
+	}
+
+	step, err := VerifyQRToken(session, attempt.QRToken, attempt.Now)
+	if errors.Is(err, ErrQRTokenExpired) {
+		return CheckResult{Status: CheckFailed, Message: "QR code has changed. Scan it again"}
+	}
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Message: "QR code is not valid for this session"}
+	}
+
+	used, err := QRTokenUsed(models.GetDB(), session.ID, attempt.Student.ID, step)
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Message: "Could not check QR code"}
+	}
+	if used {
+		return CheckResult{Status: CheckFailed, Message: "QR code has already been used. Scan it again"}
+	}
+	attempt.QRStep = &step
+	return CheckResult{Status: CheckPassed}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+func TestVerifyQRToken(t *testing.T) {
+	previous := config.AppConfig.Attendance
+	t.Cleanup(func() { config.AppConfig.Attendance = previous })
+	config.AppConfig.Attendance.QRRotation = 10 * time.Second
+	config.AppConfig.Attendance.QRGraceSteps = 1
+
+	session := &models.AttendanceSession{ID: "session-1", QRSecret: "secret-1"}
+	other := &models.AttendanceSession{ID: "session-1", QRSecret: "secret-2"}
+	now := time.Unix(1_700_000_005, 0)
+	current := CurrentQRCode(session, now)
+
+	tokenAt := func(s *models.AttendanceSession, step int64) string {
+		return CurrentQRCode(s, time.Unix(step*10, 0)).Token
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantStep int64
+		wantErr  error
+	}{
+		{"current step", current.Token, current.Step, nil},
+		{"one grace step back", tokenAt(session, current.Step-1), current.Step - 1, nil},
+		{"beyond the grace steps", tokenAt(session, current.Step-2), 0, ErrQRTokenExpired},
+		{"future step", tokenAt(session, current.Step+1), 0, ErrQRTokenExpired},
+		{"signed with another secret", tokenAt(other, current.Step), 0, ErrQRTokenInvalid},
+		{"for another session", strings.Replace(current.Token, "session-1", "session-2", 1), 0, ErrQRTokenInvalid},
+		{"tampered step", "session-1." + strconv.FormatInt(current.Step-1, 10) + "." + strings.Split(current.Token, ".")[2], 0, ErrQRTokenInvalid},
+		{"non-numeric step", "session-1.abc." + strings.Split(current.Token, ".")[2], 0, ErrQRTokenInvalid},
+		{"malformed", "session-1", 0, ErrQRTokenInvalid},
+		{"empty", "", 0, ErrQRTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, err := VerifyQRToken(session, tt.token, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && step != tt.wantStep {
+				t.Errorf("step = %d, want %d", step, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestCurrentQRCodeRotation(t *testing.T) {
+	previous := config.AppConfig.Attendance
+	t.Cleanup(func() { config.AppConfig.Attendance = previous })
+	config.AppConfig.Attendance.QRRotation = 10 * time.Second
+
+	session := &models.AttendanceSession{ID: "session-1", QRSecret: "secret-1"}
+	tests := []struct {
+		name     string
+		a, b     time.Time
+		sameCode bool
+	}{
+		{"same step", time.Unix(1_700_000_000, 0), time.Unix(1_700_000_009, 0), true},
+		{"next step", time.Unix(1_700_000_009, 0), time.Unix(1_700_000_010, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := CurrentQRCode(session, tt.a), CurrentQRCode(session, tt.b)
+			if (a.Token == b.Token) != tt.sameCode {
+				t.Errorf("tokens %q and %q: same = %v, want %v", a.Token, b.Token, a.Token == b.Token, tt.sameCode)
+			}
+			if !a.ExpiresAt.After(tt.a) {
+				t.Errorf("ExpiresAt %v is not after %v", a.ExpiresAt, tt.a)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimitStore counts hits per key in fixed windows. MemoryRateLimitStore
+// only sees its own instance's traffic; deployments with several replicas
+// should plug in a shared implementation (e.g. Redis INCR with EXPIRE).
+type RateLimitStore interface {
+	// Increment records a hit for key and returns the number of hits in the
+	// current window and when that window resets.
+	Increment(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+	// Reset forgets all hits for key.
+	Reset(ctx context.Context, key string) error
+}
+
+var rateLimitStore RateLimitStore = NewMemoryRateLimitStore()
+
+// SetRateLimitStore replaces the store used by CheckRateLimit.
+func SetRateLimitStore(store RateLimitStore) {
+	rateLimitStore = store
+}
+
+// CheckRateLimit records a hit for key and reports whether it is within
+// limit. When it is not, the returned duration is how long until the window
+// resets.
+func CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	if limit <= 0 {
+		return true, 0, nil
+	}
+	count, resetAt, err := rateLimitStore.Increment(ctx, key, window)
+	if err != nil {
+		return false, 0, err
+	}
+	if count > limit {
+		return false, time.Until(resetAt), nil
+	}
+	return true, 0, nil
+}
+
+// ResetRateLimit clears the hits recorded for key.
+func ResetRateLimit(ctx context.Context, key string) error {
+	return rateLimitStore.Reset(ctx, key)
+}
+
+type rateWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// MemoryRateLimitStore keeps counters in process memory.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{windows: make(map[string]*rateWindow), lastSweep: time.Now()}
+}
+
+func (s *MemoryRateLimitStore) Increment(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	w, ok := s.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &rateWindow{resetAt: now.Add(window)}
+		s.windows[key] = w
+	}
+	w.count++
+	return w.count, w.resetAt, nil
+}
+
+func (s *MemoryRateLimitStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.windows, key)
+	return nil
+}
+
+// sweep drops expired windows at most once a minute so memory stays bounded.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	for key, w := range s.windows {
+		if !now.Before(w.resetAt) {
+			delete(s.windows, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckRateLimit(t *testing.T) {
+	previous := rateLimitStore
+	t.Cleanup(func() { rateLimitStore = previous })
+
+	tests := []struct {
+		name        string
+		limit       int
+		hits        int
+		resetAfter  int
+		wantAllowed bool
+	}{
+		{"first hit", 3, 1, 0, true},
+		{"at the limit", 3, 3, 0, true},
+		{"over the limit", 3, 4, 0, false},
+		{"well over the limit", 3, 10, 0, false},
+		{"reset clears the count", 3, 5, 4, true},
+		{"no limit", 0, 100, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetRateLimitStore(NewMemoryRateLimitStore())
+			ctx := context.Background()
+
+			var (
+				allowed    bool
+				retryAfter time.Duration
+				err        error
+			)
+			for i := 1; i <= tt.hits; i++ {
+				allowed, retryAfter, err = CheckRateLimit(ctx, "login:ip:10.0.0.1", tt.limit, time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if i == tt.resetAfter {
+					if err := ResetRateLimit(ctx, "login:ip:10.0.0.1"); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if !allowed && (retryAfter <= 0 || retryAfter > time.Minute) {
+				t.Errorf("retryAfter = %s, want within the window", retryAfter)
+			}
+
+			// Other keys keep their own count
+			if ok, _, _ := CheckRateLimit(ctx, "login:ip:10.0.0.2", tt.limit, time.Minute); !ok {
+				t.Error("another key was limited")
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreWindow(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		wait      time.Duration
+		wantCount int
+	}{
+		{"first hit opens the window", 0, 1},
+		{"same window", 0, 2},
+		{"window expired", 60 * time.Millisecond, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(tt.wait)
+			count, resetAt, err := store.Increment(ctx, "key", 50*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+			if !resetAt.After(time.Now()) {
+				t.Errorf("resetAt %v is not in the future", resetAt)
+			}
+		})
+	}
+}
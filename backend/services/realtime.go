@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+// Message types pushed to connected clients
+const (
+	RealtimeSessionScheduled = "session_scheduled"
+	RealtimeSessionStarted   = "session_started"
+	RealtimeSessionClosed    = "session_closed"
+	RealtimeAttendanceMarked = "attendance_marked"
+)
+
+// realtimeClientBuffer is how many messages a slow client may fall behind
+// before further messages to it are dropped
+const realtimeClientBuffer = 64
+
+// UserTopic carries messages for a single user.
+func UserTopic(userID string) string {
+	return "user:" + userID
+}
+
+// SectionTopic carries messages for every student enrolled in a section.
+func SectionTopic(sectionID string) string {
+	return "section:" + sectionID
+}
+
+// AcademicYearTopic carries messages for every student in an academic year.
+func AcademicYearTopic(academicYear string) string {
+	return "year:" + academicYear
+}
+
+// RealtimeBroker fans messages out to every server instance. Start is called
+// once with the function that hands messages to this instance's clients.
+type RealtimeBroker interface {
+	Publish(ctx context.Context, msg models.RealtimeMessage) error
+	Start(ctx context.Context, deliver func(models.RealtimeMessage)) error
+}
+
+// LocalBroker delivers messages within this process only. Use it when a
+// single server instance is running.
+type LocalBroker struct {
+	mu      sync.RWMutex
+	deliver func(models.RealtimeMessage)
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{}
+}
+
+func (b *LocalBroker) Publish(ctx context.Context, msg models.RealtimeMessage) error {
+	b.mu.RLock()
+	deliver := b.deliver
+	b.mu.RUnlock()
+	if deliver != nil {
+		msg.CreatedAt = time.Now()
+		deliver(msg)
+	}
+	return nil
+}
+
+func (b *LocalBroker) Start(ctx context.Context, deliver func(models.RealtimeMessage)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deliver = deliver
+	return nil
+}
+
+// DatabaseBroker shares messages between instances through the
+// realtime_messages table. Every instance polls for rows created within the
+// last Overlap and delivers the ones it has not delivered yet: IDs are
+// assigned before commit, so a row can become visible after a higher one has
+// already been read. Old rows are pruned after Retention.
+type DatabaseBroker struct {
+	PollInterval time.Duration
+	Retention    time.Duration
+	Overlap      time.Duration
+}
+
+// databaseBrokerPage is how many rows one poll query reads at a time
+const databaseBrokerPage = 500
+
+func NewDatabaseBroker(pollInterval, retention, overlap time.Duration) *DatabaseBroker {
+	return &DatabaseBroker{PollInterval: pollInterval, Retention: retention, Overlap: overlap}
+}
+
+func (b *DatabaseBroker) Publish(ctx context.Context, msg models.RealtimeMessage) error {
+	return models.GetDB().WithContext(ctx).Create(&msg).Error
+}
+
+func (b *DatabaseBroker) Start(ctx context.Context, deliver func(models.RealtimeMessage)) error {
+	// Only messages published after startup are delivered
+	started := time.Now()
+	delivered := make(map[uint64]time.Time)
+
+	go func() {
+		poll := time.NewTicker(b.PollInterval)
+		defer poll.Stop()
+		prune := time.NewTicker(time.Minute)
+		defer prune.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-poll.C:
+				b.poll(ctx, started, delivered, deliver)
+			case <-prune.C:
+				if err := models.GetDB().WithContext(ctx).
+					Where("created_at < ?", time.Now().Add(-b.Retention)).
+					Delete(&models.RealtimeMessage{}).Error; err != nil {
+					log.Printf("realtime: failed to prune messages: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// poll delivers the messages created since the overlap window opened that
+// are not in delivered, then forgets IDs that have left the window.
+func (b *DatabaseBroker) poll(ctx context.Context, started time.Time, delivered map[uint64]time.Time, deliver func(models.RealtimeMessage)) {
+	since := time.Now().Add(-b.Overlap)
+	if since.Before(started) {
+		since = started
+	}
+
+	var cursor uint64
+	for {
+		var messages []models.RealtimeMessage
+		if err := models.GetDB().WithContext(ctx).
+			Where("created_at >= ? AND id > ?", since, cursor).Order("id asc").Limit(databaseBrokerPage).
+			Find(&messages).Error; err != nil {
+			log.Printf("realtime: failed to poll messages: %v", err)
+			return
+		}
+		for _, msg := range messages {
+			cursor = msg.ID
+			if _, ok := delivered[msg.ID]; ok {
+				continue
+			}
+			delivered[msg.ID] = msg.CreatedAt
+			deliver(msg)
+		}
+		if len(messages) < databaseBrokerPage {
+			break
+		}
+	}
+
+	for id, createdAt := range delivered {
+		if createdAt.Before(since) {
+			delete(delivered, id)
+		}
+	}
+}
+
+// RealtimeClient is one connected stream. Messages for its topics arrive on
+// Messages until it is unsubscribed.
+type RealtimeClient struct {
+	Messages chan models.RealtimeMessage
+	topics   []string
+}
+
+// RealtimeHub tracks the clients connected to this instance by topic.
+type RealtimeHub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*RealtimeClient]struct{}
+}
+
+func NewRealtimeHub() *RealtimeHub {
+	return &RealtimeHub{topics: make(map[string]map[*RealtimeClient]struct{})}
+}
+
+// Subscribe registers a client for the given topics.
+func (h *RealtimeHub) Subscribe(topics ...string) *RealtimeClient {
+	client := &RealtimeClient{Messages: make(chan models.RealtimeMessage, realtimeClientBuffer), topics: topics}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*RealtimeClient]struct{})
+		}
+		h.topics[topic][client] = struct{}{}
+	}
+	return client
+}
+
+// Unsubscribe removes a client from all of its topics.
+func (h *RealtimeHub) Unsubscribe(client *RealtimeClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range client.topics {
+		delete(h.topics[topic], client)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// Deliver hands a message to every client subscribed to its topic without
+// blocking on slow clients.
+func (h *RealtimeHub) Deliver(msg models.RealtimeMessage) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.topics[msg.Topic] {
+		select {
+		case client.Messages <- msg:
+		default:
+			log.Printf("realtime: dropped %s message for slow client on %s", msg.Type, msg.Topic)
+		}
+	}
+}
+
+var (
+	realtimeHub    = NewRealtimeHub()
+	realtimeBroker RealtimeBroker
+)
+
+// NewRealtimeBroker returns the broker named in the configuration.
+func NewRealtimeBroker(cfg config.RealtimeConfig) (RealtimeBroker, error) {
+	switch cfg.Broker {
+	case "", "local":
+		return NewLocalBroker(), nil
+	case "database":
+		return NewDatabaseBroker(cfg.PollInterval, cfg.Retention, cfg.PollOverlap), nil
+	default:
+		return nil, fmt.Errorf("unknown realtime broker %q", cfg.Broker)
+	}
+}
+
+// InitRealtime starts the configured broker and forwards session and
+// attendance events to connected clients.
+func InitRealtime(ctx context.Context, cfg config.RealtimeConfig) error {
+	broker, err := NewRealtimeBroker(cfg)
+	if err != nil {
+		return err
+	}
+	if err := broker.Start(ctx, realtimeHub.Deliver); err != nil {
+		return err
+	}
+	realtimeBroker = broker
+
+	SubscribeEvents(EventSessionScheduled, forwardSessionScheduled)
+	SubscribeEvents(EventSessionStarted, forwardSessionStarted)
+	SubscribeEvents(EventSessionClosed, forwardSessionClosed)
+	SubscribeEvents(EventAttendanceMarked, forwardAttendanceMarked)
+	return nil
+}
+
+// SubscribeRealtime connects a client to this instance's hub.
+func SubscribeRealtime(topics ...string) *RealtimeClient {
+	return realtimeHub.Subscribe(topics...)
+}
+
+// UnsubscribeRealtime disconnects a client from this instance's hub.
+func UnsubscribeRealtime(client *RealtimeClient) {
+	realtimeHub.Unsubscribe(client)
+}
+
+// PublishRealtime sends a message to the clients subscribed to topic on every
+// instance. It does nothing until InitRealtime has run.
+func PublishRealtime(topic, messageType string, payload interface{}) {
+	if realtimeBroker == nil {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("realtime: failed to encode %s message: %v", messageType, err)
+		return
+	}
+	msg := models.RealtimeMessage{Topic: topic, Type: messageType, Payload: data}
+	if err := realtimeBroker.Publish(context.Background(), msg); err != nil {
+		log.Printf("realtime: failed to publish %s message: %v", messageType, err)
+	}
+}
+
+// forwardSessionScheduled asks the teacher to confirm a session opened from
+// the timetable.
+func forwardSessionScheduled(event Event) {
+	scheduled, ok := event.Payload.(SessionScheduledEvent)
+	if !ok {
+		return
+	}
+	PublishRealtime(UserTopic(scheduled.TeacherID), RealtimeSessionScheduled, scheduled)
+}
+
+func forwardSessionStarted(event Event) {
+	started, ok := event.Payload.(SessionStartedEvent)
+	if !ok {
+		return
+	}
+	if started.SectionID != nil {
+		PublishRealtime(SectionTopic(*started.SectionID), RealtimeSessionStarted, started)
+	} else {
+		PublishRealtime(AcademicYearTopic(started.AcademicYear), RealtimeSessionStarted, started)
+	}
+	PublishRealtime(UserTopic(started.TeacherID), RealtimeSessionStarted, started)
+}
+
+func forwardSessionClosed(event Event) {
+	closed, ok := event.Payload.(SessionClosedEvent)
+	if !ok {
+		return
+	}
+	if closed.SectionID != nil {
+		PublishRealtime(SectionTopic(*closed.SectionID), RealtimeSessionClosed, closed)
+	} else {
+		PublishRealtime(AcademicYearTopic(closed.AcademicYear), RealtimeSessionClosed, closed)
+	}
+	PublishRealtime(UserTopic(closed.TeacherID), RealtimeSessionClosed, closed)
+}
+
+// forwardAttendanceMarked tells the session's teacher about the mark along
+// with the session's running counts.
+func forwardAttendanceMarked(event Event) {
+	marked, ok := event.Payload.(AttendanceMarkedEvent)
+	if !ok {
+		return
+	}
+
+	db := models.GetDB()
+	var session models.AttendanceSession
+	if err := db.Where("id = ?", marked.SessionID).First(&session).Error; err != nil {
+		log.Printf("realtime: failed to load session %s: %v", marked.SessionID, err)
+		return
+	}
+
+	var present int64
+	db.Model(&models.AttendanceRecord{}).
+		Where("session_id = ? AND status IN ?", session.ID, models.AttendedStatuses).
+		Count(&present)
+	eligible, _ := EligibleStudentIDs(db, &session)
+
+	PublishRealtime(UserTopic(marked.TeacherID), RealtimeAttendanceMarked, map[string]interface{}{
+		"record": marked,
+		"counts": map[string]int{
+			"present":  int(present),
+			"eligible": len(eligible),
+		},
+	})
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+const refreshTokenType = "refresh"
+
+var (
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token has expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// RefreshClaims are the claims carried by refresh tokens. The jti identifies
+// the persisted models.RefreshToken row.
+type RefreshClaims struct {
+	FamilyID  string `json:"fam"`
+	TokenType string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair is returned on login and on every refresh.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// IssueTokenPair starts a new refresh token family for a fresh login.
+func IssueTokenPair(user models.User, userAgent, ipAddress string) (*TokenPair, error) {
+	var pair *TokenPair
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		family := models.RefreshTokenFamily{
+			UserID:    user.ID,
+			UserAgent: truncate(userAgent, 255),
+			IPAddress: ipAddress,
+		}
+		if err := tx.Create(&family).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = issueInFamily(tx, user, family.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new token pair in the
+// same family. Presenting a token that was already rotated revokes the whole
+// family and returns ErrRefreshTokenReused.
+func RotateRefreshToken(tokenString string) (*TokenPair, *models.User, error) {
+	claims, err := parseRefreshToken(tokenString, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		pair   *TokenPair
+		user   models.User
+		reused bool
+	)
+	err = models.GetDB().Transaction(func(tx *gorm.DB) error {
+		var stored models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND family_id = ?", claims.ID, claims.FamilyID).
+			First(&stored).Error; err != nil {
+			return ErrRefreshTokenInvalid
+		}
+
+		var family models.RefreshTokenFamily
+		if err := tx.Where("id = ?", stored.FamilyID).First(&family).Error; err != nil {
+			return ErrRefreshTokenInvalid
+		}
+
+		switch err := checkRefreshToken(&stored, &family); {
+		case errors.Is(err, ErrRefreshTokenReused):
+			family.Revoke("refresh_token_reuse")
+			if err := tx.Save(&family).Error; err != nil {
+				return err
+			}
+			reused = true
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if err := tx.Where("id = ?", stored.UserID).First(&user).Error; err != nil {
+			return ErrRefreshTokenInvalid
+		}
+
+		stored.MarkAsUsed()
+		if err := tx.Save(&stored).Error; err != nil {
+			return err
+		}
+		family.LastUsedAt = time.Now()
+		if err := tx.Save(&family).Error; err != nil {
+			return err
+		}
+
+		pair, err = issueInFamily(tx, user, family.ID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if reused {
+		return nil, nil, ErrRefreshTokenReused
+	}
+	return pair, &user, nil
+}
+
+// RevokeRefreshToken revokes the family the given refresh token belongs to.
+// Expired tokens are accepted so a client can always log out.
+func RevokeRefreshToken(tokenString string) error {
+	claims, err := parseRefreshToken(tokenString, false)
+	if err != nil {
+		return err
+	}
+
+	var family models.RefreshTokenFamily
+	db := models.GetDB()
+	if err := db.Where("id = ?", claims.FamilyID).First(&family).Error; err != nil {
+		return ErrRefreshTokenInvalid
+	}
+	if family.IsRevoked() {
+		return nil
+	}
+	family.Revoke("logout")
+	return db.Save(&family).Error
+}
+
+// checkRefreshToken decides whether a stored refresh token may be rotated. A
+// token that was already rotated is reuse, even once it has expired; tokens of
+// a revoked family are simply invalid.
+func checkRefreshToken(stored *models.RefreshToken, family *models.RefreshTokenFamily) error {
+	switch {
+	case family.IsRevoked():
+		return ErrRefreshTokenInvalid
+	case stored.IsUsed():
+		return ErrRefreshTokenReused
+	case stored.IsExpired():
+		return ErrRefreshTokenExpired
+	}
+	return nil
+}
+
+func issueInFamily(tx *gorm.DB, user models.User, familyID string) (*TokenPair, error) {
+	refreshSecret := config.AppConfig.JWT.RefreshSecret
+	if refreshSecret == "" {
+		return nil, errors.New("JWT refresh secret not configured")
+	}
+
+	now := time.Now()
+	stored := models.RefreshToken{
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		ExpiresAt: now.Add(config.AppConfig.JWT.RefreshExpiry),
+	}
+	if err := tx.Create(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	claims := RefreshClaims{
+		FamilyID:  familyID,
+		TokenType: refreshTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        stored.ID,
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(stored.ExpiresAt),
+		},
+	}
+	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(refreshSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := GenerateToken(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(config.AppConfig.JWT.TokenExpiry.Seconds()),
+	}, nil
+}
+
+func parseRefreshToken(tokenString string, validateExpiry bool) (*RefreshClaims, error) {
+	opts := []jwt.ParserOption{jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()})}
+	if !validateExpiry {
+		opts = append(opts, jwt.WithoutClaimsValidation())
+	}
+
+	claims := &RefreshClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.AppConfig.JWT.RefreshSecret), nil
+	}, opts...)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrRefreshTokenExpired
+		}
+		return nil, ErrRefreshTokenInvalid
+	}
+	if claims.TokenType != refreshTokenType || claims.ID == "" || claims.FamilyID == "" {
+		return nil, ErrRefreshTokenInvalid
+	}
+	return claims, nil
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
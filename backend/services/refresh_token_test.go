@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+func TestParseRefreshToken(t *testing.T) {
+	previous := config.AppConfig.JWT
+	t.Cleanup(func() { config.AppConfig.JWT = previous })
+	config.AppConfig.JWT.RefreshSecret = "refresh-secret"
+
+	now := time.Now()
+	claims := func(edit func(*RefreshClaims)) RefreshClaims {
+		c := RefreshClaims{
+			FamilyID:  "family-1",
+			TokenType: refreshTokenType,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "token-1",
+				Subject:   "user-1",
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		}
+		if edit != nil {
+			edit(&c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, c RefreshClaims, secret string) string {
+		token, err := jwt.NewWithClaims(method, c).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	expired := claims(func(c *RefreshClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) })
+
+	tests := []struct {
+		name           string
+		token          string
+		validateExpiry bool
+		wantErr        error
+	}{
+		{"valid", sign(jwt.SigningMethodHS256, claims(nil), "refresh-secret"), true, nil},
+		{"expired", sign(jwt.SigningMethodHS256, expired, "refresh-secret"), true, ErrRefreshTokenExpired},
+		{"expired is accepted for logout", sign(jwt.SigningMethodHS256, expired, "refresh-secret"), false, nil},
+		{"signed with another secret", sign(jwt.SigningMethodHS256, claims(nil), "other-secret"), true, ErrRefreshTokenInvalid},
+		{"signed with another algorithm", sign(jwt.SigningMethodHS512, claims(nil), "refresh-secret"), true, ErrRefreshTokenInvalid},
+		{"not a refresh token", sign(jwt.SigningMethodHS256, claims(func(c *RefreshClaims) { c.TokenType = "" }), "refresh-secret"), true, ErrRefreshTokenInvalid},
+		{"no family", sign(jwt.SigningMethodHS256, claims(func(c *RefreshClaims) { c.FamilyID = "" }), "refresh-secret"), true, ErrRefreshTokenInvalid},
+		{"no token id", sign(jwt.SigningMethodHS256, claims(func(c *RefreshClaims) { c.ID = "" }), "refresh-secret"), true, ErrRefreshTokenInvalid},
+		{"malformed", "not-a-token", true, ErrRefreshTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRefreshToken(tt.token, tt.validateExpiry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.ID != "token-1" || got.FamilyID != "family-1") {
+				t.Errorf("claims = %+v, want token-1 in family-1", got)
+			}
+		})
+	}
+}
+
+func TestCheckRefreshToken(t *testing.T) {
+	earlier := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		token   models.RefreshToken
+		family  models.RefreshTokenFamily
+		wantErr error
+	}{
+		{"unused and current", models.RefreshToken{ExpiresAt: later}, models.RefreshTokenFamily{}, nil},
+		{"already rotated is reuse", models.RefreshToken{ExpiresAt: later, UsedAt: &earlier}, models.RefreshTokenFamily{}, ErrRefreshTokenReused},
+		{"reuse is reported even once expired", models.RefreshToken{ExpiresAt: earlier, UsedAt: &earlier}, models.RefreshTokenFamily{}, ErrRefreshTokenReused},
+		{"expired", models.RefreshToken{ExpiresAt: earlier}, models.RefreshTokenFamily{}, ErrRefreshTokenExpired},
+		{"revoked family", models.RefreshToken{ExpiresAt: later}, models.RefreshTokenFamily{RevokedAt: &earlier}, ErrRefreshTokenInvalid},
+		{"reuse in a revoked family", models.RefreshToken{ExpiresAt: later, UsedAt: &earlier}, models.RefreshTokenFamily{RevokedAt: &earlier}, ErrRefreshTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkRefreshToken(&tt.token, &tt.family); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/models"
+)
+
+var ErrLoginNotFound = errors.New("login session not found")
+
+// ActiveLogins returns the user's logins that have not been revoked and can
+// still be refreshed.
+func ActiveLogins(userID string) ([]models.RefreshTokenFamily, error) {
+	var families []models.RefreshTokenFamily
+	err := models.GetDB().
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Where("EXISTS (SELECT 1 FROM refresh_tokens WHERE refresh_tokens.family_id = refresh_token_families.id AND refresh_tokens.used_at IS NULL AND refresh_tokens.expires_at > ?)", time.Now()).
+		Order("last_used_at desc").
+		Find(&families).Error
+	return families, err
+}
+
+// RevokeLogin revokes a single login of the user. Access tokens issued for it
+// are rejected by the auth middleware from then on.
+func RevokeLogin(userID, sessionID, reason string) error {
+	db := models.GetDB()
+	var family models.RefreshTokenFamily
+	if err := db.Where("id = ? AND user_id = ?", sessionID, userID).First(&family).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrLoginNotFound
+		}
+		return err
+	}
+	if family.IsRevoked() {
+		return nil
+	}
+	family.Revoke(reason)
+	return db.Save(&family).Error
+}
+
+// RevokeAllLogins bumps the user's token version, invalidating every access
+// token already issued, and revokes all of their refresh token families. When
+// db is a transaction the revocation commits or rolls back with it.
+func RevokeAllLogins(db *gorm.DB, userID, reason string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshTokenFamily{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error
+	})
+}
+
+// RevokeAdminTokens bumps the admin's token version, invalidating every admin
+// token already issued to them. Admin logins have no refresh tokens, so this
+// is both how admins log out and how they are forced out.
+func RevokeAdminTokens(adminID string) error {
+	return models.GetDB().Model(&models.Admin{}).Where("id = ?", adminID).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// IsLoginRevoked reports whether the login an access token was issued for has
+// been revoked.
+func IsLoginRevoked(userID, sessionID string) (bool, error) {
+	var family models.RefreshTokenFamily
+	err := models.GetDB().Select("id", "revoked_at").
+		Where("id = ? AND user_id = ?", sessionID, userID).First(&family).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return family.IsRevoked(), nil
+}
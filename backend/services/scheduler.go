@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/models"
+	"smart_attendance_backend/utils"
+)
+
+// Scheduler runs background jobs on fixed intervals. Each run first takes a
+// lease in scheduler_locks, so when several replicas share the database only
+// one of them runs a given job at a time.
+type Scheduler struct {
+	instanceID string
+	jobs       []scheduledJob
+}
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+func NewScheduler() *Scheduler {
+	hostname, _ := os.Hostname()
+	return &Scheduler{instanceID: fmt.Sprintf("%s-%s", hostname, utils.GenerateUUID()[:8])}
+}
+
+// Every registers a job to run on the given interval.
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, scheduledJob{name: name, interval: interval, run: run})
+}
+
+// Start runs every registered job until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.loop(ctx, job)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job scheduledJob) {
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job scheduledJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("scheduler: job %s panicked: %v", job.name, r)
+		}
+	}()
+
+	// The lease outlives one interval so a slow run is not picked up elsewhere
+	acquired, err := s.acquireLease(job.name, 2*job.interval)
+	if err != nil {
+		log.Printf("scheduler: failed to acquire lease for %s: %v", job.name, err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	if err := job.run(ctx); err != nil {
+		log.Printf("scheduler: job %s failed: %v", job.name, err)
+	}
+}
+
+// acquireLease takes or renews the named lease for this instance. It succeeds
+// when the lease is free, expired or already held by this instance.
+func (s *Scheduler) acquireLease(name string, ttl time.Duration) (bool, error) {
+	db := models.GetDB()
+	now := time.Now()
+
+	lock := models.SchedulerLock{Name: name, Holder: "", ExpiresAt: time.Unix(0, 0)}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock).Error; err != nil {
+		return false, err
+	}
+
+	result := db.Model(&models.SchedulerLock{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, s.instanceID, now).
+		Updates(map[string]interface{}{"holder": s.instanceID, "expires_at": now.Add(ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
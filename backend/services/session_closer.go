@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/models"
+)
+
+var ErrSessionNotActive = errors.New("session is not active")
+
+// closeExpiredBatchSize bounds how many sessions one scheduler run closes
+const closeExpiredBatchSize = 100
+
+// CloseSession ends an active session as completed or cancelled, or cancels
+// a scheduled one. Completing a session records an absence for every
+// eligible student who did not mark. EventSessionClosed is published once the
+// change is committed.
+func CloseSession(sessionID string, status models.SessionStatus, endedAt time.Time) (*models.AttendanceSession, error) {
+	var (
+		session models.AttendanceSession
+		event   SessionClosedEvent
+	)
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", sessionID).First(&session).Error; err != nil {
+			return err
+		}
+		// A scheduled session the teacher never confirmed can only be cancelled
+		if !session.IsActive() && !(session.IsScheduled() && status == models.SessionStatusCanceled) {
+			return ErrSessionNotActive
+		}
+
+		// Ending early closes the session now rather than when the countdown runs out
+		if endedAt.Before(session.EndTime) {
+			session.EndTime = endedAt
+		}
+		if status == models.SessionStatusCanceled {
+			session.Cancel()
+		} else {
+			session.Complete()
+		}
+		if err := tx.Omit(clause.Associations).Save(&session).Error; err != nil {
+			return err
+		}
+
+		absent := 0
+		if session.Status == models.SessionStatusComplete {
+			var err error
+			if absent, err = finalizeAbsentees(tx, &session); err != nil {
+				return err
+			}
+		}
+
+		var present int64
+		if err := tx.Model(&models.AttendanceRecord{}).
+			Where("session_id = ? AND status IN ?", session.ID, models.AttendedStatuses).
+			Count(&present).Error; err != nil {
+			return err
+		}
+
+		event = SessionClosedEvent{
+			SessionID:    session.ID,
+			TeacherID:    session.TeacherID,
+			SubjectID:    session.SubjectID,
+			AcademicYear: session.AcademicYear,
+			SectionID:    session.SectionID,
+			Status:       string(session.Status),
+			PresentCount: int(present),
+			AbsentCount:  absent,
+			ClosedAt:     session.EndTime,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	PublishEvent(EventSessionClosed, event)
+	return &session, nil
+}
+
+// CloseExpiredSessions completes active sessions whose countdown has run out.
+// It is run periodically by the scheduler.
+func CloseExpiredSessions(ctx context.Context) error {
+	var sessionIDs []string
+	if err := models.GetDB().Model(&models.AttendanceSession{}).
+		Where("status = ? AND end_time <= ?", models.SessionStatusActive, time.Now()).
+		Order("end_time asc").Limit(closeExpiredBatchSize).
+		Pluck("id", &sessionIDs).Error; err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// endedAt is past EndTime, so EndTime stays as the countdown set it
+		if _, err := CloseSession(sessionID, models.SessionStatusComplete, time.Now()); err != nil && !errors.Is(err, ErrSessionNotActive) {
+			log.Printf("failed to close expired session %s: %v", sessionID, err)
+		}
+	}
+	return nil
+}
+
+// finalizeAbsentees records an absence for each eligible student without a
+// record in the session and returns how many were created.
+func finalizeAbsentees(tx *gorm.DB, session *models.AttendanceSession) (int, error) {
+	eligible, err := EligibleStudentIDs(tx, session)
+	if err != nil {
+		return 0, err
+	}
+
+	var marked []string
+	if err := tx.Model(&models.AttendanceRecord{}).Where("session_id = ?", session.ID).Pluck("student_id", &marked).Error; err != nil {
+		return 0, err
+	}
+	markedSet := make(map[string]bool, len(marked))
+	for _, id := range marked {
+		markedSet[id] = true
+	}
+
+	var absences []models.AttendanceRecord
+	for _, studentID := range eligible {
+		if markedSet[studentID] {
+			continue
+		}
+		absences = append(absences, models.AttendanceRecord{
+			SessionID:          session.ID,
+			StudentID:          studentID,
+			Status:             models.AttendanceStatusAbsent,
+			MarkedAt:           session.EndTime,
+			VerificationMethod: models.VerificationMethodNone,
+		})
+	}
+	if len(absences) == 0 {
+		return 0, nil
+	}
+
+	// A mark committed after the list above was read keeps its record
+	if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(absences, 200).Error; err != nil {
+		return 0, err
+	}
+	return len(absences), nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"smart_attendance_backend/config"
+)
+
+// SMSProvider delivers text messages such as OTP codes.
+type SMSProvider interface {
+	Name() string
+	Send(ctx context.Context, to, body string) error
+}
+
+var (
+	smsProvider SMSProvider = &ConsoleSMSProvider{}
+	smsTimeout              = 10 * time.Second
+)
+
+// InitSMS selects the SMS provider named in the configuration.
+func InitSMS(cfg config.SMSConfig) error {
+	provider, err := NewSMSProvider(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Timeout > 0 {
+		smsTimeout = cfg.Timeout
+	}
+	SetSMSProvider(provider)
+	log.Printf("SMS provider: %s", provider.Name())
+	return nil
+}
+
+// NewSMSProvider builds the provider named by cfg.Provider.
+func NewSMSProvider(cfg config.SMSConfig) (SMSProvider, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch strings.ToLower(cfg.Provider) {
+	case "twilio":
+		if cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.TwilioFrom == "" {
+			return nil, fmt.Errorf("Twilio credentials (TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN, TWILIO_FROM) not set")
+		}
+		return &TwilioSMSProvider{
+			BaseURL:    cfg.TwilioBaseURL,
+			AccountSID: cfg.TwilioAccountSID,
+			AuthToken:  cfg.TwilioAuthToken,
+			From:       cfg.TwilioFrom,
+			Client:     client,
+		}, nil
+	case "webhook":
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("SMS_WEBHOOK_URL not set")
+		}
+		return &WebhookSMSProvider{URL: cfg.WebhookURL, Token: cfg.WebhookToken, Client: client}, nil
+	case "console", "":
+		return &ConsoleSMSProvider{}, nil
+	case "memory":
+		return NewMemorySMSProvider(), nil
+	}
+	return nil, fmt.Errorf("unknown SMS provider %q", cfg.Provider)
+}
+
+// SetSMSProvider replaces the provider used by SendSMS.
+func SetSMSProvider(provider SMSProvider) {
+	smsProvider = provider
+}
+
+// CurrentSMSProvider returns the provider used by SendSMS.
+func CurrentSMSProvider() SMSProvider {
+	return smsProvider
+}
+
+// SendSMS sends an SMS with the provided message to the given phone number
+// using the configured provider.
+func SendSMS(phone string, message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), smsTimeout)
+	defer cancel()
+
+	if err := smsProvider.Send(ctx, phone, message); err != nil {
+		return fmt.Errorf("%s: %w", smsProvider.Name(), err)
+	}
+	return nil
+}
+
+// ConsoleSMSProvider writes messages to the log instead of sending them.
+// Intended for local development only.
+type ConsoleSMSProvider struct{}
+
+func (p *ConsoleSMSProvider) Name() string {
+	return "console"
+}
+
+func (p *ConsoleSMSProvider) Send(ctx context.Context, to, body string) error {
+	log.Printf("[sms] to=%s body=%q", to, body)
+	return nil
+}
+
+// SMSMessage is a message captured by MemorySMSProvider.
+type SMSMessage struct {
+	To     string
+	Body   string
+	SentAt time.Time
+}
+
+// MemorySMSProvider keeps sent messages in memory so tests can read OTPs back.
+type MemorySMSProvider struct {
+	mu       sync.Mutex
+	messages []SMSMessage
+}
+
+func NewMemorySMSProvider() *MemorySMSProvider {
+	return &MemorySMSProvider{}
+}
+
+func (p *MemorySMSProvider) Name() string {
+	return "memory"
+}
+
+func (p *MemorySMSProvider) Send(ctx context.Context, to, body string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, SMSMessage{To: to, Body: body, SentAt: time.Now()})
+	return nil
+}
+
+// Messages returns a copy of every captured message in send order.
+func (p *MemorySMSProvider) Messages() []SMSMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]SMSMessage(nil), p.messages...)
+}
+
+// LastMessageTo returns the most recent message sent to the phone number.
+func (p *MemorySMSProvider) LastMessageTo(to string) (SMSMessage, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := len(p.messages) - 1; i >= 0; i-- {
+		if p.messages[i].To == to {
+			return p.messages[i], true
+		}
+	}
+	return SMSMessage{}, false
+}
+
+// Reset discards all captured messages.
+func (p *MemorySMSProvider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = nil
+}
+
+// WebhookSMSProvider posts messages as JSON to an HTTP endpoint, for SMS
+// gateways other than Twilio.
+type WebhookSMSProvider struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (p *WebhookSMSProvider) Name() string {
+	return "webhook"
+}
+
+func (p *WebhookSMSProvider) Send(ctx context.Context, to, body string) error {
+	payload, err := json.Marshal(map[string]string{"to": to, "body": body})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+// StatsGroup picks what attendance statistics are summarised by
+type StatsGroup int
+
+const (
+	StatsBySubject StatsGroup = iota
+	StatsByStudent
+)
+
+// How excused absences count towards the attendance percentage
+const (
+	ExcusedExclude = "exclude"
+	ExcusedPresent = "present"
+	ExcusedAbsent  = "absent"
+)
+
+// StatsPolicy is how late marks and excused absences are weighted in the
+// attendance percentage
+type StatsPolicy struct {
+	LateWeight    float64 `json:"late_weight"`
+	ExcusedPolicy string  `json:"excused_policy"`
+}
+
+// CurrentStatsPolicy returns the configured policy, with the late weight
+// clamped to [0, 1] and unknown excused policies treated as exclude
+func CurrentStatsPolicy() StatsPolicy {
+	policy := StatsPolicy{
+		LateWeight:    math.Min(math.Max(config.AppConfig.Attendance.LateWeight, 0), 1),
+		ExcusedPolicy: config.AppConfig.Attendance.ExcusedPolicy,
+	}
+	switch policy.ExcusedPolicy {
+	case ExcusedPresent, ExcusedAbsent:
+	default:
+		policy.ExcusedPolicy = ExcusedExclude
+	}
+	return policy
+}
+
+// StatsFilter narrows the sessions counted in attendance statistics. From and
+// To are dates, inclusive; a zero value leaves that end of the range open.
+type StatsFilter struct {
+	StudentID string
+	SubjectID string
+	SectionID *string
+	From      time.Time
+	To        time.Time
+}
+
+// AttendanceSummary is a student's attendance in a subject, or one side of
+// that pair depending on the StatsGroup.
+type AttendanceSummary struct {
+	StudentID string `json:"student_id,omitempty"`
+	SubjectID string `json:"subject_id,omitempty"`
+	// Sessions counts the completed sessions held on teaching days
+	Sessions       int `json:"sessions"`
+	Present        int `json:"present"`
+	Late           int `json:"late"`
+	ManuallyMarked int `json:"manually_marked"`
+	Absent         int `json:"absent"`
+	Excused        int `json:"excused"`
+	// Percentage weights late marks and excused absences by the StatsPolicy
+	Percentage float64 `json:"percentage"`
+	// ExcludedSessions were held on non-teaching days and are not counted
+	ExcludedSessions int `json:"excluded_sessions"`
+}
+
+// AttendanceStats summarises attendance records of completed sessions,
+// leaving out sessions held on non-teaching days of the academic calendar.
+func AttendanceStats(filter StatsFilter, group StatsGroup, policy StatsPolicy) ([]AttendanceSummary, error) {
+	query := models.GetDB().Model(&models.AttendanceRecord{}).
+		Select("attendance_records.student_id, attendance_records.status, attendance_sessions.subject_id, attendance_sessions.start_time").
+		Joins("JOIN attendance_sessions ON attendance_sessions.id = attendance_records.session_id AND attendance_sessions.deleted_at IS NULL").
+		Where("attendance_sessions.status = ?", models.SessionStatusComplete)
+	if filter.StudentID != "" {
+		query = query.Where("attendance_records.student_id = ?", filter.StudentID)
+	}
+	if filter.SubjectID != "" {
+		query = query.Where("attendance_sessions.subject_id = ?", filter.SubjectID)
+	}
+	if filter.SectionID != nil {
+		query = query.Where("attendance_sessions.section_id = ?", *filter.SectionID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("attendance_sessions.start_time >= ?", localDate(filter.From))
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("attendance_sessions.start_time < ?", localDate(filter.To).AddDate(0, 0, 1))
+	}
+
+	var rows []struct {
+		StudentID string
+		Status    models.AttendanceStatus
+		SubjectID string
+		StartTime time.Time
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	calendar, err := LoadCalendar(filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]*AttendanceSummary)
+	var order []string
+	for _, row := range rows {
+		key := row.SubjectID
+		if group == StatsByStudent {
+			key = row.StudentID
+		}
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &AttendanceSummary{}
+			if group == StatsByStudent {
+				summary.StudentID = key
+			} else {
+				summary.SubjectID = key
+			}
+			summaries[key] = summary
+			order = append(order, key)
+		}
+
+		if !calendar.IsTeachingDay(row.StartTime.In(time.Local)) {
+			summary.ExcludedSessions++
+			continue
+		}
+		summary.Sessions++
+		switch row.Status {
+		case models.AttendanceStatusPresent:
+			summary.Present++
+		case models.AttendanceStatusLate:
+			summary.Late++
+		case models.AttendanceStatusManual:
+			summary.ManuallyMarked++
+		case models.AttendanceStatusAbsent:
+			summary.Absent++
+		case models.AttendanceStatusExcused:
+			summary.Excused++
+		}
+	}
+
+	sort.Strings(order)
+	result := make([]AttendanceSummary, 0, len(order))
+	for _, key := range order {
+		summary := summaries[key]
+		summary.Percentage = attendancePercentage(summary, policy)
+		result = append(result, *summary)
+	}
+	return result, nil
+}
+
+func attendancePercentage(summary *AttendanceSummary, policy StatsPolicy) float64 {
+	attended := float64(summary.Present+summary.ManuallyMarked) + float64(summary.Late)*policy.LateWeight
+	counted := summary.Sessions
+	switch policy.ExcusedPolicy {
+	case ExcusedPresent:
+		attended += float64(summary.Excused)
+	case ExcusedExclude:
+		counted -= summary.Excused
+	}
+	if counted <= 0 {
+		return 0
+	}
+	return math.Round(attended/float64(counted)*1000) / 10
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+
+	"smart_attendance_backend/config"
+)
+
+func TestAttendancePercentage(t *testing.T) {
+	// 10 sessions: 5 present, 1 manual, 2 late, 1 absent, 1 excused
+	summary := AttendanceSummary{Sessions: 10, Present: 5, ManuallyMarked: 1, Late: 2, Absent: 1, Excused: 1}
+
+	tests := []struct {
+		name    string
+		summary AttendanceSummary
+		policy  StatsPolicy
+		want    float64
+	}{
+		{"late counts fully, excused excluded", summary, StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedExclude}, 88.9},
+		{"late counts half, excused excluded", summary, StatsPolicy{LateWeight: 0.5, ExcusedPolicy: ExcusedExclude}, 77.8},
+		{"late not counted, excused excluded", summary, StatsPolicy{LateWeight: 0, ExcusedPolicy: ExcusedExclude}, 66.7},
+		{"excused as present", summary, StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedPresent}, 90},
+		{"excused as absent", summary, StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedAbsent}, 80},
+		{"late half, excused as present", summary, StatsPolicy{LateWeight: 0.5, ExcusedPolicy: ExcusedPresent}, 80},
+		{"all present", AttendanceSummary{Sessions: 4, Present: 4}, StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedExclude}, 100},
+		{"no sessions", AttendanceSummary{}, StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedExclude}, 0},
+		{"only excused sessions, excluded", AttendanceSummary{Sessions: 2, Excused: 2}, StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedExclude}, 0},
+		{"only excused sessions, as present", AttendanceSummary{Sessions: 2, Excused: 2}, StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedPresent}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attendancePercentage(&tt.summary, tt.policy); got != tt.want {
+				t.Errorf("attendancePercentage = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentStatsPolicy(t *testing.T) {
+	previous := config.AppConfig.Attendance
+	t.Cleanup(func() { config.AppConfig.Attendance = previous })
+
+	tests := []struct {
+		name          string
+		lateWeight    float64
+		excusedPolicy string
+		want          StatsPolicy
+	}{
+		{"configured values", 0.5, ExcusedPresent, StatsPolicy{LateWeight: 0.5, ExcusedPolicy: ExcusedPresent}},
+		{"absent policy", 1, ExcusedAbsent, StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedAbsent}},
+		{"weight above one", 1.5, ExcusedExclude, StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedExclude}},
+		{"negative weight", -0.2, ExcusedExclude, StatsPolicy{LateWeight: 0, ExcusedPolicy: ExcusedExclude}},
+		{"unknown policy", 1, "ignore", StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedExclude}},
+		{"empty policy", 1, "", StatsPolicy{LateWeight: 1, ExcusedPolicy: ExcusedExclude}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig.Attendance.LateWeight = tt.lateWeight
+			config.AppConfig.Attendance.ExcusedPolicy = tt.excusedPolicy
+			if got := CurrentStatsPolicy(); got != tt.want {
+				t.Errorf("CurrentStatsPolicy = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"smart_attendance_backend/models"
+)
+
+// dateLayout is how timetable dates are written in requests and exceptions
+const dateLayout = "2006-01-02"
+
+// ClassOccurrence is one class of a timetable entry. SessionID and
+// SessionStatus are set once a session exists for it.
+type ClassOccurrence struct {
+	Entry         *models.TimetableEntry `json:"entry"`
+	StartsAt      time.Time              `json:"starts_at"`
+	EndsAt        time.Time              `json:"ends_at"`
+	SessionID     *string                `json:"session_id,omitempty"`
+	SessionStatus *models.SessionStatus  `json:"session_status,omitempty"`
+}
+
+// ParseClock parses an "HH:MM" time of day into minutes after midnight.
+func ParseClock(value string) (int, bool) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// EntryOccurrences returns the entry's classes starting in [from, to). The
+// entry's Term and Exceptions must be loaded.
+func EntryOccurrences(entry *models.TimetableEntry, from, to time.Time) []ClassOccurrence {
+	if entry.Term == nil {
+		return nil
+	}
+	startMinute, ok := ParseClock(entry.StartsAt)
+	if !ok {
+		return nil
+	}
+	endMinute, ok := ParseClock(entry.EndsAt)
+	if !ok {
+		return nil
+	}
+
+	first := localDate(entry.Term.StartsOn)
+	if entry.ValidFrom != nil && localDate(*entry.ValidFrom).After(first) {
+		first = localDate(*entry.ValidFrom)
+	}
+	last := localDate(entry.Term.EndsOn)
+	if entry.ValidUntil != nil && localDate(*entry.ValidUntil).Before(last) {
+		last = localDate(*entry.ValidUntil)
+	}
+
+	// Recurrence is counted from the first class, not from the range asked for
+	anchor := first.AddDate(0, 0, (entry.Weekday-int(first.Weekday())+7)%7)
+	repeat := entry.RepeatEveryWeeks
+	if repeat < 1 {
+		repeat = 1
+	}
+
+	skipped := make(map[string]bool, len(entry.Exceptions))
+	for _, exception := range entry.Exceptions {
+		skipped[exception.Date.Format(dateLayout)] = true
+	}
+
+	day := localDate(from.In(time.Local))
+	if day.Before(anchor) {
+		day = anchor
+	}
+	day = day.AddDate(0, 0, (entry.Weekday-int(day.Weekday())+7)%7)
+
+	var occurrences []ClassOccurrence
+	for ; !day.After(last) && day.Before(to); day = day.AddDate(0, 0, 7) {
+		if daysBetween(anchor, day)/7%repeat != 0 || skipped[day.Format(dateLayout)] {
+			continue
+		}
+		startsAt := atClock(day, startMinute)
+		if startsAt.Before(from) || !startsAt.Before(to) {
+			continue
+		}
+		occurrences = append(occurrences, ClassOccurrence{
+			Entry:    entry,
+			StartsAt: startsAt,
+			EndsAt:   atClock(day, endMinute),
+		})
+	}
+	return occurrences
+}
+
+// UpcomingClasses returns the teacher's classes starting in [from, to) in
+// start order, with the session opened for each one if there is one. Classes
+// on non-teaching days of the academic calendar are left out.
+func UpcomingClasses(teacherID string, from, to time.Time) ([]ClassOccurrence, error) {
+	db := models.GetDB()
+	var entries []models.TimetableEntry
+	if err := db.Preload("Term").Preload("Exceptions").Preload("Subject").Preload("Section").Preload("Room").
+		Joins("JOIN terms ON terms.id = timetable_entries.term_id AND terms.deleted_at IS NULL").
+		Where("timetable_entries.teacher_id = ?", teacherID).
+		Where("terms.starts_on <= ? AND terms.ends_on >= ?", to, from.AddDate(0, 0, -1)).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	calendar, err := LoadCalendar(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var occurrences []ClassOccurrence
+	entryIDs := make([]string, 0, len(entries))
+	for i := range entries {
+		entryIDs = append(entryIDs, entries[i].ID)
+		for _, class := range EntryOccurrences(&entries[i], from, to) {
+			if calendar.IsTeachingDay(class.StartsAt) {
+				occurrences = append(occurrences, class)
+			}
+		}
+	}
+	if len(occurrences) == 0 {
+		return occurrences, nil
+	}
+
+	var sessions []models.AttendanceSession
+	if err := db.Where("timetable_entry_id IN ? AND scheduled_for >= ? AND scheduled_for < ?", entryIDs, from, to).
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	opened := make(map[string]models.AttendanceSession, len(sessions))
+	for _, session := range sessions {
+		opened[classKey(*session.TimetableEntryID, *session.ScheduledFor)] = session
+	}
+	for i := range occurrences {
+		if session, ok := opened[classKey(occurrences[i].Entry.ID, occurrences[i].StartsAt)]; ok {
+			occurrences[i].SessionID = &session.ID
+			occurrences[i].SessionStatus = &session.Status
+		}
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].StartsAt.Before(occurrences[j].StartsAt)
+	})
+	return occurrences, nil
+}
+
+// OpenScheduledSessions creates a scheduled session for every auto-open
+// class starting within lead on a teaching day, for the teacher to confirm,
+// and cancels scheduled sessions whose class has ended unconfirmed. It is
+// run periodically by the scheduler.
+func OpenScheduledSessions(ctx context.Context, lead time.Duration) error {
+	db := models.GetDB().WithContext(ctx)
+	now := time.Now()
+
+	if err := db.Model(&models.AttendanceSession{}).
+		Where("status = ? AND end_time <= ?", models.SessionStatusScheduled, now).
+		Update("status", models.SessionStatusCanceled).Error; err != nil {
+		return err
+	}
+
+	var entries []models.TimetableEntry
+	if err := db.Preload("Term").Preload("Exceptions").Preload("Room.AccessPoints").
+		Joins("JOIN terms ON terms.id = timetable_entries.term_id AND terms.deleted_at IS NULL").
+		Where("timetable_entries.auto_open = ? AND timetable_entries.room_id IS NOT NULL", true).
+		Where("terms.starts_on <= ? AND terms.ends_on >= ?", now.Add(lead), now.AddDate(0, 0, -1)).
+		Find(&entries).Error; err != nil {
+		return err
+	}
+
+	// Classes already under way are still opened, e.g. after a restart
+	from := now.AddDate(0, 0, -1)
+	calendar, err := LoadCalendar(from, now.Add(lead))
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		for _, class := range EntryOccurrences(&entries[i], from, now.Add(lead)) {
+			if !class.EndsAt.After(now) || !calendar.IsTeachingDay(class.StartsAt) {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := openScheduledSession(&entries[i], class); err != nil {
+				log.Printf("failed to open scheduled session for timetable entry %s: %v", entries[i].ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func openScheduledSession(entry *models.TimetableEntry, class ClassOccurrence) error {
+	startsAt := class.StartsAt
+	session := models.AttendanceSession{
+		TeacherID:         entry.TeacherID,
+		SubjectID:         entry.SubjectID,
+		SectionID:         entry.SectionID,
+		AcademicYear:      entry.AcademicYear,
+		StartTime:         class.StartsAt,
+		EndTime:           class.EndsAt,
+		CountdownDuration: entry.CountdownDuration,
+		Status:            models.SessionStatusScheduled,
+		QRRequired:        entry.RequireQR,
+		TimetableEntryID:  &entry.ID,
+		ScheduledFor:      &startsAt,
+	}
+	session.ApplyRoom(entry.Room)
+	if entry.RequireQR {
+		secret, err := NewQRSecret()
+		if err != nil {
+			return err
+		}
+		session.QRSecret = secret
+	}
+
+	// The unique index on (timetable_entry_id, scheduled_for) makes this a
+	// no-op for classes that already have a session
+	result := models.GetDB().Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&session)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	PublishEvent(EventSessionScheduled, SessionScheduledEvent{
+		SessionID:    session.ID,
+		TeacherID:    session.TeacherID,
+		SubjectID:    session.SubjectID,
+		AcademicYear: session.AcademicYear,
+		SectionID:    session.SectionID,
+		RoomID:       session.RoomID,
+		StartTime:    session.StartTime,
+		EndTime:      session.EndTime,
+	})
+	return nil
+}
+
+// localDate returns midnight in the server's time zone on t's calendar date.
+// Dates read from DATE columns keep their day whatever zone they carry.
+func localDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// atClock returns the wall-clock time minute minutes after midnight on day,
+// so classes keep their time on days the clocks change
+func atClock(day time.Time, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minute/60, minute%60, 0, 0, time.Local)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+func classKey(entryID string, startsAt time.Time) string {
+	return entryID + "@" + startsAt.UTC().Format(time.RFC3339)
+}
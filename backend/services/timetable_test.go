@@ -0,0 +1,128 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"smart_attendance_backend/models"
+)
+
+// useLocation runs the test with time.Local set to the named zone
+func useLocation(t *testing.T, name string) {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	previous := time.Local
+	time.Local = location
+	t.Cleanup(func() { time.Local = previous })
+}
+
+func TestEntryOccurrences(t *testing.T) {
+	useLocation(t, "Europe/Berlin")
+	date := func(value string) time.Time {
+		d, _ := time.ParseInLocation(dateLayout, value, time.Local)
+		return d
+	}
+	datePtr := func(value string) *time.Time {
+		d := date(value)
+		return &d
+	}
+	spring := &models.Term{StartsOn: date("2025-03-03"), EndsOn: date("2025-06-27")}
+	autumn := &models.Term{StartsOn: date("2025-09-01"), EndsOn: date("2025-12-19")}
+
+	tests := []struct {
+		name     string
+		entry    models.TimetableEntry
+		from, to time.Time
+		want     []string
+	}{
+		{
+			name:  "weekly",
+			entry: models.TimetableEntry{Term: spring, Weekday: 1, StartsAt: "09:00", EndsAt: "10:00"},
+			from:  date("2025-03-01"), to: date("2025-03-24"),
+			want: []string{"2025-03-03 09:00-10:00", "2025-03-10 09:00-10:00", "2025-03-17 09:00-10:00"},
+		},
+		{
+			name:  "class already started is left out",
+			entry: models.TimetableEntry{Term: spring, Weekday: 1, StartsAt: "09:00", EndsAt: "10:00"},
+			from:  date("2025-03-03").Add(9*time.Hour + 30*time.Minute), to: date("2025-03-18"),
+			want: []string{"2025-03-10 09:00-10:00", "2025-03-17 09:00-10:00"},
+		},
+		{
+			name:  "biweekly from the term start",
+			entry: models.TimetableEntry{Term: spring, Weekday: 3, StartsAt: "14:00", EndsAt: "15:30", RepeatEveryWeeks: 2},
+			from:  date("2025-03-01"), to: date("2025-04-10"),
+			want: []string{"2025-03-05 14:00-15:30", "2025-03-19 14:00-15:30", "2025-04-02 14:00-15:30"},
+		},
+		{
+			name:  "biweekly keeps its anchor when the range starts later",
+			entry: models.TimetableEntry{Term: spring, Weekday: 3, StartsAt: "14:00", EndsAt: "15:30", RepeatEveryWeeks: 2},
+			from:  date("2025-03-10"), to: date("2025-04-10"),
+			want: []string{"2025-03-19 14:00-15:30", "2025-04-02 14:00-15:30"},
+		},
+		{
+			name: "biweekly anchored on valid_from",
+			entry: models.TimetableEntry{Term: spring, Weekday: 3, StartsAt: "14:00", EndsAt: "15:30", RepeatEveryWeeks: 2,
+				ValidFrom: datePtr("2025-03-10")},
+			from: date("2025-03-01"), to: date("2025-04-01"),
+			want: []string{"2025-03-12 14:00-15:30", "2025-03-26 14:00-15:30"},
+		},
+		{
+			name:  "valid_until is inclusive",
+			entry: models.TimetableEntry{Term: spring, Weekday: 1, StartsAt: "09:00", EndsAt: "10:00", ValidUntil: datePtr("2025-03-17")},
+			from:  date("2025-03-01"), to: date("2025-04-01"),
+			want: []string{"2025-03-03 09:00-10:00", "2025-03-10 09:00-10:00", "2025-03-17 09:00-10:00"},
+		},
+		{
+			name: "exceptions are skipped",
+			entry: models.TimetableEntry{Term: spring, Weekday: 1, StartsAt: "09:00", EndsAt: "10:00",
+				Exceptions: []models.TimetableException{{Date: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)}}},
+			from: date("2025-03-01"), to: date("2025-03-18"),
+			want: []string{"2025-03-03 09:00-10:00", "2025-03-17 09:00-10:00"},
+		},
+		{
+			name:  "clocks go forward",
+			entry: models.TimetableEntry{Term: spring, Weekday: 0, StartsAt: "09:00", EndsAt: "10:00"},
+			from:  date("2025-03-23"), to: date("2025-04-07"),
+			want: []string{"2025-03-23 09:00-10:00", "2025-03-30 09:00-10:00", "2025-04-06 09:00-10:00"},
+		},
+		{
+			name:  "clocks go back",
+			entry: models.TimetableEntry{Term: autumn, Weekday: 0, StartsAt: "09:00", EndsAt: "10:00"},
+			from:  date("2025-10-19"), to: date("2025-11-03"),
+			want: []string{"2025-10-19 09:00-10:00", "2025-10-26 09:00-10:00", "2025-11-02 09:00-10:00"},
+		},
+		{
+			name:  "outside the term",
+			entry: models.TimetableEntry{Term: spring, Weekday: 1, StartsAt: "09:00", EndsAt: "10:00"},
+			from:  date("2025-07-01"), to: date("2025-08-01"),
+			want: nil,
+		},
+		{
+			name:  "term not loaded",
+			entry: models.TimetableEntry{Weekday: 1, StartsAt: "09:00", EndsAt: "10:00"},
+			from:  date("2025-03-01"), to: date("2025-04-01"),
+			want: nil,
+		},
+		{
+			name:  "invalid clock",
+			entry: models.TimetableEntry{Term: spring, Weekday: 1, StartsAt: "9am", EndsAt: "10:00"},
+			from:  date("2025-03-01"), to: date("2025-04-01"),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, class := range EntryOccurrences(&tt.entry, tt.from, tt.to) {
+				got = append(got, class.StartsAt.Format("2006-01-02 15:04")+"-"+class.EndsAt.Format("15:04"))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("occurrences = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,54 +1,59 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 )
 
-// SendSMS sends an SMS with the provided message to the given phone number using Twilio's Messaging API.
-func SendSMS(phone string, message string) error {
-	accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
-	authToken := os.Getenv("TWILIO_AUTH_TOKEN")
-	from := os.Getenv("TWILIO_FROM") // ensure you set this in your .env file
-	if accountSid == "" || authToken == "" || from == "" {
-		return fmt.Errorf("Twilio credentials (TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN, TWILIO_FROM) not set")
-	}
+// TwilioSMSProvider sends messages through Twilio's Messaging API. BaseURL
+// can point at a local stub server in development and CI.
+type TwilioSMSProvider struct {
+	BaseURL    string
+	AccountSID string
+	AuthToken  string
+	From       string
+	Client     *http.Client
+}
+
+func (p *TwilioSMSProvider) Name() string {
+	return "twilio"
+}
 
+func (p *TwilioSMSProvider) Send(ctx context.Context, to, body string) error {
 	// Twilio Messaging API endpoint
-	urlStr := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", accountSid)
+	urlStr := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimRight(p.BaseURL, "/"), p.AccountSID)
 
 	// Prepare data for the POST request
 	msgData := url.Values{}
-	msgData.Set("To", phone)
-	msgData.Set("From", from)
-	msgData.Set("Body", message)
+	msgData.Set("To", to)
+	msgData.Set("From", p.From)
+	msgData.Set("Body", body)
 	payload := strings.NewReader(msgData.Encode())
 
-	req, err := http.NewRequest("POST", urlStr, payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, payload)
 	if err != nil {
 		return err
 	}
 
-	req.SetBasicAuth(accountSid, authToken)
+	req.SetBasicAuth(p.AccountSID, p.AuthToken)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := p.Client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		bodyBytes, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("Twilio API error: %s", string(bodyBytes))
 	}
 
-	log.Printf("SMS sent to %s successfully.", phone)
+	log.Printf("SMS sent to %s successfully.", to)
 	return nil
 }
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/models"
+)
+
+// TwilioVerifyStrategy delegates code generation, delivery and checking to
+// the Twilio Verify API. A row without a code is kept in otp_verifications to
+// track purpose, expiry and attempts locally. Twilio keeps a single pending
+// verification per phone, so issuing a code invalidates outstanding codes for
+// every purpose. BaseURL can point at a local stand-in.
+type TwilioVerifyStrategy struct {
+	BaseURL    string
+	ServiceSID string
+	AccountSID string
+	AuthToken  string
+	Client     *http.Client
+}
+
+type twilioVerification struct {
+	SID    string `json:"sid"`
+	Status string `json:"status"`
+	Valid  bool   `json:"valid"`
+}
+
+func (s *TwilioVerifyStrategy) Name() string {
+	return "twilio_verify"
+}
+
+func (s *TwilioVerifyStrategy) Send(ctx context.Context, user models.User, purpose models.OTPPurpose) error {
+	form := url.Values{}
+	form.Set("To", user.Phone)
+	form.Set("Channel", "sms")
+
+	if _, err := s.post(ctx, "Verifications", form); err != nil {
+		return err
+	}
+
+	return issueOTPRecord(user, purpose, "", true)
+}
+
+func (s *TwilioVerifyStrategy) Check(ctx context.Context, user models.User, purpose models.OTPPurpose, code string, onVerified func(tx *gorm.DB) error) error {
+	return checkOutstandingOTP(user, purpose, func(otpRec *models.OTPVerification) (bool, error) {
+		form := url.Values{}
+		form.Set("To", user.Phone)
+		form.Set("Code", code)
+
+		result, err := s.post(ctx, "VerificationCheck", form)
+		if err != nil {
+			return false, err
+		}
+		if result == nil {
+			// Twilio answers 404 once the verification has expired or been used
+			return false, ErrOTPExpired
+		}
+		return result.Status == "approved", nil
+	}, onVerified)
+}
+
+// post calls a Verify service endpoint. A nil result with a nil error means
+// Twilio answered 404.
+func (s *TwilioVerifyStrategy) post(ctx context.Context, resource string, form url.Values) (*twilioVerification, error) {
+	urlStr := fmt.Sprintf("%s/v2/Services/%s/%s", strings.TrimRight(s.BaseURL, "/"), s.ServiceSID, resource)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(s.AccountSID, s.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Twilio Verify API error: %s", string(bodyBytes))
+	}
+
+	var result twilioVerification
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
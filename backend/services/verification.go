@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"smart_attendance_backend/config"
+	"smart_attendance_backend/models"
+)
+
+// Names of the checks in the default verification pipeline
+const (
+	CheckSessionOpen   = "session_open"
+	CheckEnrollment    = "enrollment"
+	CheckGeofence      = "geofence"
+	CheckWifiBSSID     = "wifi_bssid"
+	CheckDeviceBinding = "device_binding"
+	CheckDeveloperMode = "developer_mode"
+	CheckAttestation   = "attestation"
+	CheckQRToken       = "qr_token"
+	CheckPresence      = "presence"
+)
+
+type CheckStatus string
+
+const (
+	CheckPassed  CheckStatus = "passed"
+	CheckFailed  CheckStatus = "failed"
+	CheckSkipped CheckStatus = "skipped"
+)
+
+// CheckResult is the outcome of one verifier, returned to the app so it can
+// show exactly which check failed. Internal details are only written to the
+// audit log, for values that would help a client spoof the check.
+type CheckResult struct {
+	Name     string                 `json:"name"`
+	Status   CheckStatus            `json:"status"`
+	Message  string                 `json:"message,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+	Internal map[string]interface{} `json:"-"`
+}
+
+// MarkAttempt is everything a verifier may inspect about an attendance mark.
+type MarkAttempt struct {
+	Student   *models.User
+	Session   *models.AttendanceSession
+	Latitude  *float64
+	Longitude *float64
+	// Accuracy is the reported GPS accuracy radius in meters
+	Accuracy  *float64
+	WifiSSID  *string
+	WifiBSSID *string
+	Device    models.DeviceInfoStruct
+	// Nonce and Signature prove the mark came from the bound device's key
+	Nonce     string
+	Signature string
+	// QRToken is the token scanned from the teacher's screen, if any
+	QRToken string
+	// BoundDevice is set by DeviceBindingVerifier once the device is confirmed
+	BoundDevice *models.UserDevice
+	// QRStep and Challenge are set by the verifiers that accepted them, to be
+	// spent by ConsumeTokens when the mark is stored
+	QRStep    *int64
+	Challenge *models.AttendanceChallenge
+	Now       time.Time
+}
+
+// ConsumeTokens spends the QR step and signed challenge the attempt was
+// verified with. It returns ErrQRTokenReplayed or ErrChallengeUsed if a
+// concurrent mark spent them first.
+func (a *MarkAttempt) ConsumeTokens(tx *gorm.DB) error {
+	if a.QRStep != nil {
+		if err := ConsumeQRToken(tx, a.Session.ID, a.Student.ID, *a.QRStep); err != nil {
+			return err
+		}
+	}
+	if a.Challenge != nil {
+		if err := ConsumeChallenge(tx, a.Challenge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verifier checks one aspect of an attendance mark.
+type Verifier interface {
+	Name() string
+	Verify(ctx context.Context, attempt *MarkAttempt) CheckResult
+}
+
+// VerificationResult is the combined outcome of a pipeline run.
+type VerificationResult struct {
+	Passed bool
+	Method models.VerificationMethod
+	Checks []CheckResult
+}
+
+// Check returns the result of the named check, if it ran.
+func (r *VerificationResult) Check(name string) (CheckResult, bool) {
+	for _, check := range r.Checks {
+		if check.Name == name {
+			return check, true
+		}
+	}
+	return CheckResult{}, false
+}
+
+// AuditChecks returns the checks with their internal details merged in, for
+// the audit log.
+func (r *VerificationResult) AuditChecks() []CheckResult {
+	checks := make([]CheckResult, len(r.Checks))
+	for i, check := range r.Checks {
+		checks[i] = check
+		if len(check.Internal) == 0 {
+			continue
+		}
+		details := make(map[string]interface{}, len(check.Details)+len(check.Internal))
+		for key, value := range check.Details {
+			details[key] = value
+		}
+		for key, value := range check.Internal {
+			details[key] = value
+		}
+		checks[i].Details = details
+	}
+	return checks
+}
+
+// VerificationPipeline runs a chain of verifiers. Every verifier runs even
+// after a failure so the caller gets a complete breakdown.
+type VerificationPipeline struct {
+	verifiers         []Verifier
+	requireAllFactors bool
+}
+
+func NewVerificationPipeline(requireAllFactors bool, verifiers ...Verifier) *VerificationPipeline {
+	return &VerificationPipeline{verifiers: verifiers, requireAllFactors: requireAllFactors}
+}
+
+// Use appends a verifier to the pipeline.
+func (p *VerificationPipeline) Use(verifier Verifier) {
+	p.verifiers = append(p.verifiers, verifier)
+}
+
+// DefaultVerificationPipeline builds the pipeline used by /attendance/mark
+// from the attendance configuration.
+func DefaultVerificationPipeline() *VerificationPipeline {
+	cfg := config.AppConfig.Attendance
+	return NewVerificationPipeline(cfg.RequireAllFactors,
+		SessionOpenVerifier{},
+		EnrollmentVerifier{},
+		GeofenceVerifier{
+			DefaultRadiusMeters: cfg.GeofenceRadiusMeters,
+			MaxAccuracyMeters:   cfg.MaxGPSAccuracyMeters,
+			ToleranceMeters:     cfg.GeofenceToleranceMeters,
+		},
+		WifiBSSIDVerifier{},
+		QRTokenVerifier{},
+		DeviceBindingVerifier{},
+		AttestationVerifier{Required: cfg.RequireAttestation},
+		DeveloperModeVerifier{},
+	)
+}
+
+// Run verifies the attempt. A mark passes when no check failed and the
+// student's presence was proven by location, Wi-Fi or both, as configured.
+func (p *VerificationPipeline) Run(ctx context.Context, attempt *MarkAttempt) VerificationResult {
+	if attempt.Now.IsZero() {
+		attempt.Now = time.Now()
+	}
+
+	result := VerificationResult{Passed: true}
+	for _, verifier := range p.verifiers {
+		check := verifier.Verify(ctx, attempt)
+		check.Name = verifier.Name()
+		if check.Status == CheckFailed {
+			result.Passed = false
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	location, _ := result.Check(CheckGeofence)
+	wifi, _ := result.Check(CheckWifiBSSID)
+	locationOK := location.Status == CheckPassed
+	wifiOK := wifi.Status == CheckPassed
+
+	switch {
+	case locationOK && wifiOK:
+		result.Method = models.VerificationMethodBoth
+	case locationOK:
+		result.Method = models.VerificationMethodLocation
+	case wifiOK:
+		result.Method = models.VerificationMethodWifi
+	}
+	if qr, ok := result.Check(CheckQRToken); ok && qr.Status == CheckPassed {
+		result.Method = result.Method.WithQR()
+	}
+
+	presence := CheckResult{Name: CheckPresence, Status: CheckPassed}
+	if (p.requireAllFactors && !(locationOK && wifiOK)) || (!locationOK && !wifiOK) {
+		presence.Status = CheckFailed
+		if p.requireAllFactors {
+			presence.Message = "Both location and Wi-Fi verification are required"
+		} else {
+			presence.Message = "Location or Wi-Fi verification is required"
+		}
+		result.Passed = false
+	}
+	result.Checks = append(result.Checks, presence)
+
+	return result
+}
+
+// SessionOpenVerifier requires the session to be active with time left on
+// its countdown.
+type SessionOpenVerifier struct{}
+
+func (SessionOpenVerifier) Name() string { return CheckSessionOpen }
+
+func (SessionOpenVerifier) Verify(ctx context.Context, attempt *MarkAttempt) CheckResult {
+	session := attempt.Session
+	if !session.IsActive() {
+		return CheckResult{Status: CheckFailed, Message: "Session is no longer active"}
+	}
+	if !attempt.Now.Before(session.EndTime) {
+		return CheckResult{Status: CheckFailed, Message: "Session countdown has ended"}
+	}
+	return CheckResult{Status: CheckPassed}
+}
+
+// GeofenceVerifier requires the reported location to be inside the session's
+// geofence: a polygon if the session defines one, otherwise a circle of the
+// session's radius or DefaultRadiusMeters. Fixes less accurate than
+// MaxAccuracyMeters are rejected. The reported accuracy allows a fix at most
+// ToleranceMeters beyond the boundary; it never widens the fence further.
+type GeofenceVerifier struct {
+	DefaultRadiusMeters float64
+	MaxAccuracyMeters   float64
+	ToleranceMeters     float64
+}
+
+func (GeofenceVerifier) Name() string { return CheckGeofence }
+
+func (v GeofenceVerifier) Verify(ctx context.Context, attempt *MarkAttempt) CheckResult {
+	if attempt.Latitude == nil || attempt.Longitude == nil {
+		return CheckResult{Status: CheckSkipped, Message: "Location not provided"}
+	}
+
+	accuracy := 0.0
+	if attempt.Accuracy != nil {
+		accuracy = math.Max(0, *attempt.Accuracy)
+	}
+	if v.MaxAccuracyMeters > 0 && accuracy > v.MaxAccuracyMeters {
+		return CheckResult{
+			Status:  CheckFailed,
+			Message: "GPS signal is too weak. Move closer to a window and try again",
+			Details: map[string]interface{}{"accuracy_meters": accuracy, "max_accuracy_meters": v.MaxAccuracyMeters},
+		}
+	}
+
+	fence, err := SessionGeofence(attempt.Session, v.DefaultRadiusMeters)
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Message: "Session geofence is invalid"}
+	}
+
+	match := fence.Evaluate(models.GeoPoint{Lat: *attempt.Latitude, Lng: *attempt.Longitude}, math.Min(accuracy, v.ToleranceMeters))
+	details := map[string]interface{}{"accuracy_meters": accuracy, "geofence": "circle"}
+	internal := map[string]interface{}{
+		"distance_meters": roundMeters(match.DistanceMeters),
+		"outside_meters":  roundMeters(match.OutsideMeters),
+	}
+	if fence.IsPolygon() {
+		details["geofence"] = "polygon"
+	} else {
+		internal["radius_meters"] = fence.RadiusMeters
+	}
+
+	if !match.Inside {
+		return CheckResult{
+			Status:   CheckFailed,
+			Message:  "You are outside the classroom area",
+			Details:  details,
+			Internal: internal,
+		}
+	}
+	return CheckResult{Status: CheckPassed, Details: details, Internal: internal}
+}
+
+func roundMeters(meters float64) float64 {
+	return math.Round(meters*10) / 10
+}
+
+// WifiBSSIDVerifier requires the connected access point to be the one
+// recorded on the session or any access point registered in the session's
+// room. The room's access points must be preloaded.
+type WifiBSSIDVerifier struct{}
+
+func (WifiBSSIDVerifier) Name() string { return CheckWifiBSSID }
+
+func (WifiBSSIDVerifier) Verify(ctx context.Context, attempt *MarkAttempt) CheckResult {
+	if attempt.WifiBSSID == nil || *attempt.WifiBSSID == "" {
+		return CheckResult{Status: CheckSkipped, Message: "Wi-Fi not provided"}
+	}
+	bssid := NormalizeBSSID(*attempt.WifiBSSID)
+	if attempt.Session.WifiBSSID != "" && bssid == NormalizeBSSID(attempt.Session.WifiBSSID) {
+		return CheckResult{Status: CheckPassed}
+	}
+	if room := attempt.Session.Room; room != nil && room.HasBSSID(bssid) {
+		return CheckResult{Status: CheckPassed, Details: map[string]interface{}{"room_id": room.ID}}
+	}
+	return CheckResult{Status: CheckFailed, Message: "You are not connected to the classroom Wi-Fi"}
+}
+
+// DeviceBindingVerifier requires the request to come from the device bound
+// to the student's account. The client's own DeviceRegistered flag is ignored.
+type DeviceBindingVerifier struct{}
+
+func (DeviceBindingVerifier) Name() string { return CheckDeviceBinding }
+
+func (DeviceBindingVerifier) Verify(ctx context.Context, attempt *MarkAttempt) CheckResult {
+	if attempt.Device.DeviceID == "" {
+		return CheckResult{Status: CheckFailed, Message: "Device ID not provided"}
+	}
+
+	device, err := ActiveDevice(attempt.Student.ID)
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Message: "Could not check device binding"}
+	}
+	if device == nil {
+		return CheckResult{Status: CheckFailed, Message: "No device is bound to your account. Sign in again on your phone to bind it"}
+	}
+	if device.DeviceID != attempt.Device.DeviceID {
+		return CheckResult{Status: CheckFailed, Message: "This device is not registered to your account"}
+	}
+	attempt.BoundDevice = device
+	return CheckResult{Status: CheckPassed}
+}
+
+// DeveloperModeVerifier rejects devices with developer options enabled, which
+// make location spoofing trivial.
+type DeveloperModeVerifier struct{}
+
+func (DeveloperModeVerifier) Name() string { return CheckDeveloperMode }
+
+func (DeveloperModeVerifier) Verify(ctx context.Context, attempt *MarkAttempt) CheckResult {
+	if attempt.Device.DeveloperMode {
+		return CheckResult{Status: CheckFailed, Message: "Disable developer mode to mark attendance"}
+	}
+	return CheckResult{Status: CheckPassed}
+}
+
+// ValidBSSID reports whether bssid is a 48-bit MAC address.
+func ValidBSSID(bssid string) bool {
+	mac, err := net.ParseMAC(NormalizeBSSID(bssid))
+	return err == nil && len(mac) == 6
+}
+
+// NormalizeBSSID lowercases a MAC address and uses colons as separators.
+func NormalizeBSSID(bssid string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(bssid)), "-", ":")
+}